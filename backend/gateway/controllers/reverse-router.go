@@ -16,8 +16,8 @@ const (
 )
 
 const (
-	webPrefix        string = "/web/"
-	apiPrefix        string = "/api"
+	webPrefix        string = common.GWWebPrefix
+	apiPrefix        string = common.GWAPIPrefix
 	userSrvPrefix    string = "/user/"
 	linkSrvPrefix    string = "/link/"
 	redirectorPrefix string = ""
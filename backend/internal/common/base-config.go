@@ -47,6 +47,8 @@ type BaseCfgInfo struct {
 
 	CFSupport bool // cloud flare 功能支援
 
+	ShortCode ShortCodeInfo
+
 	SrvcKeyPath string
 	SrvcAddrMap ServiceAddrMap // Service Address Map
 	MgoDB       MgoDBInfo
@@ -54,6 +56,11 @@ type BaseCfgInfo struct {
 	Log         LogInfo
 }
 
+// ShortCodeInfo 客製化短網址的正規化設定，link 與 redirector 需要使用相同的設定
+type ShortCodeInfo struct {
+	CaseFold string // 大小寫的處理方式: keep(預設) 保留大小寫、lower 不區分大小寫
+}
+
 // ServiceAddrInfo service 的連線地址資訊
 type ServiceAddrInfo struct {
 	REST string // 一般 REST API 的 address
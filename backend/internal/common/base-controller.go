@@ -219,11 +219,10 @@ type UserInfo struct {
 	Email           string
 	Role            uint32
 	IsManager       bool
-	NormalLinkQuota uint64   // 一般短網址額度
-	NormalLinkUsage uint64   // 一般短網址使用量
-	CustomLinkQuota uint64   // 自訂短網址額度
-	CustomLinkUsage uint64   // 自訂短網址使用量
-	LinkTags        []string // 使用者定義的短網址 tags
+	NormalLinkQuota uint64 // 一般短網址額度
+	NormalLinkUsage uint64 // 一般短網址使用量
+	CustomLinkQuota uint64 // 自訂短網址額度
+	CustomLinkUsage uint64 // 自訂短網址使用量
 }
 
 // UserGetByID 取得指定 ID 的 user 資料 (透過 gRPC 呼叫 user service)
@@ -262,3 +261,57 @@ func (bc *BaseController) UserRequestGet(ctx context.Context) (*UserInfo, error)
 
 	return bc.UserGetByID(ctx, sessVals.UserIDHex)
 }
+
+// 工作區成員的權限，與 user service 的 WorkspaceRole 相同
+const (
+	WorkspaceRoleAdmin  uint32 = 1 // 管理成員與邀請，並可以編輯所有短網址
+	WorkspaceRoleEditor uint32 = 2 // 可以建立、編輯、刪除工作區的短網址
+	WorkspaceRoleViewer uint32 = 3 // 只能查看工作區的短網址
+)
+
+// WorkspaceMemberInfo 使用者在工作區中的權限與工作區的額度
+type WorkspaceMemberInfo struct {
+	WorkspaceID     primitive.ObjectID
+	WorkspaceIDHex  string
+	Role            uint32
+	NormalLinkQuota uint64 // 工作區一般短網址額度
+	NormalLinkUsage uint64 // 工作區一般短網址使用量
+	CustomLinkQuota uint64 // 工作區自訂短網址額度
+	CustomLinkUsage uint64 // 工作區自訂短網址使用量
+}
+
+// CanEdit 是否可以建立、編輯工作區的短網址
+func (m *WorkspaceMemberInfo) CanEdit() bool {
+	return m.Role == WorkspaceRoleAdmin || m.Role == WorkspaceRoleEditor
+}
+
+// WorkspaceMemberGet 取得 user 在工作區中的權限 (透過 gRPC 呼叫 user service)
+//
+// user 不是工作區成員時回傳 NotFound 錯誤
+func (bc *BaseController) WorkspaceMemberGet(ctx context.Context,
+	workspaceIDHex, userIDHex string) (*WorkspaceMemberInfo, error) {
+	resp, err := bc.SrvcConn.User.WorkspaceMemberGet(ctx, &userPB.WorkspaceMemberGetRequest{
+		WorkspaceIdHex: workspaceIDHex,
+		UserIdHex:      userIDHex,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pbWorkspaceInfo := resp.GetWorkspaceInfo()
+	objID, err := primitive.ObjectIDFromHex(pbWorkspaceInfo.GetIdHex())
+	if err != nil {
+		bc.Logger.Error("primitive.ObjectIDFromHex failed", zap.Error(err))
+		return nil, err
+	}
+
+	return &WorkspaceMemberInfo{
+		WorkspaceID:     objID,
+		WorkspaceIDHex:  pbWorkspaceInfo.GetIdHex(),
+		Role:            pbWorkspaceInfo.GetRole(),
+		NormalLinkQuota: pbWorkspaceInfo.GetNormalQuota(),
+		NormalLinkUsage: pbWorkspaceInfo.GetNormalUsage(),
+		CustomLinkQuota: pbWorkspaceInfo.GetCustomQuota(),
+		CustomLinkUsage: pbWorkspaceInfo.GetCustomUsage(),
+	}, nil
+}
@@ -122,7 +122,8 @@ func LoggerCodeToLevel(code codes.Code) zapcore.Level {
 	}
 }
 
-func NewGRPCServer(logger *zap.Logger) *grpc.Server {
+// NewGRPCServer 建立包含 log 與 panic recovery 的 gRPC server，opts 會被加到預設的設定之後
+func NewGRPCServer(logger *zap.Logger, opts ...grpc.ServerOption) *grpc.Server {
 	recoveryFunc := func(p interface{}) (err error) {
 		logger.Error("panic error", zap.Any("msg", p))
 		err = GRPCErrInternal
@@ -130,7 +131,7 @@ func NewGRPCServer(logger *zap.Logger) *grpc.Server {
 	}
 
 	midLogger := logger.WithOptions(zap.WithCaller(false), zap.AddStacktrace(zap.PanicLevel))
-	gs := grpc.NewServer(
+	opts = append([]grpc.ServerOption{
 		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
 			grpc_zap.StreamServerInterceptor(midLogger, grpc_zap.WithLevels(LoggerCodeToLevel)),
 			grpc_recovery.StreamServerInterceptor(grpc_recovery.WithRecoveryHandler(recoveryFunc)),
@@ -139,7 +140,8 @@ func NewGRPCServer(logger *zap.Logger) *grpc.Server {
 			grpc_zap.UnaryServerInterceptor(midLogger, grpc_zap.WithLevels(LoggerCodeToLevel)),
 			grpc_recovery.UnaryServerInterceptor(grpc_recovery.WithRecoveryHandler(recoveryFunc)),
 		)),
-	)
+	}, opts...)
+	gs := grpc.NewServer(opts...)
 
 	return gs
 }
@@ -0,0 +1,13 @@
+package common
+
+// gateway 轉發到各個服務的路由前綴，其餘路徑會被轉發到 redirector
+const (
+	GWWebPrefix string = "/web/"
+	GWAPIPrefix string = "/api"
+)
+
+// redirector 直接轉交給網頁處理的路徑
+const (
+	RDRootPath    string = "/"
+	RDFaviconPath string = "/favicon.ico"
+)
@@ -0,0 +1,152 @@
+// Package lrucache 有容量上限並支援過期時間的 LRU 快取
+package lrucache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type entry[K comparable, V any] struct {
+	key      K
+	val      V
+	expireAt time.Time
+}
+
+// Cache 並行安全的 LRU 快取，超過容量時移除最久沒有被使用的資料
+type Cache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[K]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	now func() time.Time
+}
+
+// New 建立最多保存 capacity 筆資料的快取，capacity 小於 1 時視為 1
+func New[K comparable, V any](capacity int) *Cache[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache[K, V]{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[K]*list.Element, capacity),
+		now:      time.Now,
+	}
+}
+
+// Get 取得 key 對應的資料，不存在或已過期時回傳 false
+func (c *Cache[K, V]) Get(key K) (val V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exist := c.items[key]
+	if !exist {
+		c.misses.Add(1)
+		return val, false
+	}
+	e := elem.Value.(*entry[K, V])
+	if !c.now().Before(e.expireAt) {
+		c.removeElement(elem)
+		c.misses.Add(1)
+		return val, false
+	}
+
+	c.ll.MoveToFront(elem)
+	c.hits.Add(1)
+	return e.val, true
+}
+
+// Peek 取得 key 對應的資料，不影響使用順序與使用統計
+func (c *Cache[K, V]) Peek(key K) (val V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exist := c.items[key]
+	if !exist {
+		return val, false
+	}
+	e := elem.Value.(*entry[K, V])
+	if !c.now().Before(e.expireAt) {
+		return val, false
+	}
+
+	return e.val, true
+}
+
+// Set 設定 key 的資料，ttl 後過期
+func (c *Cache[K, V]) Set(key K, val V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expireAt := c.now().Add(ttl)
+	if elem, exist := c.items[key]; exist {
+		e := elem.Value.(*entry[K, V])
+		e.val, e.expireAt = val, expireAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, val: val, expireAt: expireAt})
+	if c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Delete 移除 key 的資料
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exist := c.items[key]; exist {
+		c.removeElement(elem)
+	}
+}
+
+// Purge 移除所有資料
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[K]*list.Element, c.capacity)
+}
+
+func (c *Cache[K, V]) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*entry[K, V]).key)
+}
+
+// Stats 快取的使用統計
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	Len    int
+}
+
+// HitRatio 回傳命中率，沒有任何查詢時回傳 0
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Stats 回傳目前的使用統計
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	length := c.ll.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Len:    length,
+	}
+}
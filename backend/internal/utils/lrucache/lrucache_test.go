@@ -0,0 +1,89 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheEvict(t *testing.T) {
+	c := New[string, int](2)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a should exist")
+	}
+	c.Set("c", 3, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %d, %v", v, ok)
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("Get(c) = %d, %v", v, ok)
+	}
+}
+
+func TestCacheExpire(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := New[string, *int](10)
+	c.now = func() time.Time { return now }
+
+	c.Set("neg", nil, time.Second)
+	if v, ok := c.Get("neg"); !ok || v != nil {
+		t.Fatalf("Get(neg) = %v, %v, want nil, true", v, ok)
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := c.Get("neg"); ok {
+		t.Error("neg should be expired")
+	}
+	if c.Stats().Len != 0 {
+		t.Error("expired entry should be removed")
+	}
+}
+
+func TestCacheDeleteAndStats(t *testing.T) {
+	c := New[string, int](10)
+	c.Set("a", 1, time.Minute)
+	c.Set("a", 2, time.Minute)
+	if v, _ := c.Get("a"); v != 2 {
+		t.Errorf("Get(a) = %d, want 2", v)
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("a should be deleted")
+	}
+	c.Set("b", 1, time.Minute)
+	c.Purge()
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should be purged")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Len != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if r := stats.HitRatio(); r < 0.33 || r > 0.34 {
+		t.Errorf("HitRatio() = %f", r)
+	}
+}
+
+func TestCachePeek(t *testing.T) {
+	c := New[string, int](2)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Errorf("Peek(a) = %d, %v", v, ok)
+	}
+
+	// Peek 不會更新使用順序，a 仍然是最久沒有被使用的資料
+	c.Set("c", 3, time.Minute)
+	if _, ok := c.Peek("a"); ok {
+		t.Error("a should be evicted")
+	}
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Peek should not change stats, got %+v", stats)
+	}
+}
@@ -0,0 +1,79 @@
+// Package ratewindow 統計最近一段時間內事件的累計值
+package ratewindow
+
+import (
+	"sync"
+	"time"
+)
+
+// Counter 並行安全的滑動視窗計數器，將視窗分為固定數量的區間，過期的區間會被清除
+type Counter struct {
+	mu      sync.Mutex
+	buckets []int64
+	width   time.Duration // 每個區間的長度
+	span    time.Duration // 視窗的長度
+	cur     int64         // 目前區間的編號
+
+	now func() time.Time
+}
+
+// New 建立統計最近 span 的計數器，視窗被分為 n 個區間，n 小於 1 時視為 1
+func New(span time.Duration, n int) *Counter {
+	if n < 1 {
+		n = 1
+	}
+	width := span / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	return &Counter{
+		buckets: make([]int64, n),
+		width:   width,
+		span:    width * time.Duration(n),
+		now:     time.Now,
+	}
+}
+
+// advance 將目前區間移動到現在的時間，並清除已過期的區間
+func (c *Counter) advance() {
+	idx := c.now().UnixNano() / int64(c.width)
+	n := int64(len(c.buckets))
+	if idx-c.cur >= n {
+		for i := range c.buckets {
+			c.buckets[i] = 0
+		}
+	} else {
+		for i := c.cur + 1; i <= idx; i++ {
+			c.buckets[i%n] = 0
+		}
+	}
+	if idx > c.cur {
+		c.cur = idx
+	}
+}
+
+// Add 將 delta 加到目前的區間
+func (c *Counter) Add(delta int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.advance()
+	c.buckets[c.cur%int64(len(c.buckets))] += delta
+}
+
+// Sum 回傳最近 span 內的累計值
+func (c *Counter) Sum() (sum int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.advance()
+	for _, v := range c.buckets {
+		sum += v
+	}
+	return sum
+}
+
+// Rate 回傳最近 span 內平均每秒的累計值，啟動後未滿 span 時同樣以 span 計算
+func (c *Counter) Rate() float64 {
+	return float64(c.Sum()) / c.span.Seconds()
+}
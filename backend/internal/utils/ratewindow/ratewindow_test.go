@@ -0,0 +1,73 @@
+package ratewindow
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 測試用的時間，只有在呼叫 add 時才會前進
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time      { return f.t }
+func (f *fakeClock) add(d time.Duration) { f.t = f.t.Add(d) }
+
+func newTestCounter(span time.Duration, n int) (*Counter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	c := New(span, n)
+	c.now = clock.now
+	return c, clock
+}
+
+func TestCounterSlide(t *testing.T) {
+	c, clock := newTestCounter(10*time.Second, 10)
+
+	c.Add(5)
+	clock.add(3 * time.Second)
+	c.Add(2)
+	if sum := c.Sum(); sum != 7 {
+		t.Fatalf("Sum() = %d, want 7", sum)
+	}
+	if rate := c.Rate(); rate != 0.7 {
+		t.Errorf("Rate() = %f, want 0.7", rate)
+	}
+
+	// 第一次加入的值在 10 秒後過期
+	clock.add(7 * time.Second)
+	if sum := c.Sum(); sum != 2 {
+		t.Errorf("Sum() = %d, want 2", sum)
+	}
+
+	// 超過整個視窗後所有的值都過期
+	clock.add(time.Minute)
+	if sum := c.Sum(); sum != 0 {
+		t.Errorf("Sum() = %d, want 0", sum)
+	}
+	c.Add(1)
+	if sum := c.Sum(); sum != 1 {
+		t.Errorf("Sum() = %d, want 1", sum)
+	}
+}
+
+func TestCounterConcurrent(t *testing.T) {
+	c := New(time.Minute, 60)
+
+	const workers, perWorker = 8, 1000
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				c.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sum := c.Sum(); sum != workers*perWorker {
+		t.Errorf("Sum() = %d, want %d", sum, workers*perWorker)
+	}
+}
@@ -1,8 +1,53 @@
 package configs
 
-import "URLS/internal/common"
+import (
+	"URLS/internal/common"
+	"time"
+)
+
+// URLPolicyInfo 目的地網址的檢查規則
+type URLPolicyInfo struct {
+	AllowSchemes        []string // 允許的 scheme，為空時只允許 http、https
+	BlocklistPath       string   // 封鎖網域清單的檔案路徑，每行一個網域
+	AllowIPLiteral      bool     // 是否允許以 IP 作為目的地的 host
+	AllowPrivateNetwork bool     // 是否允許私有網段、本機等目的地
+}
+
+// HealthCheckInfo 目的地健康檢查的設定
+type HealthCheckInfo struct {
+	Enable             bool
+	Interval           time.Duration // 同一個短網址的檢查間隔，預設 24 小時
+	Concurrency        int           // 同時進行的檢查數量，預設 20
+	RatePerSec         int           // 每秒最多發出的請求數，預設 10
+	PerHostConcurrency int           // 同一個 host 同時進行的檢查數量，預設 2
+	Timeout            time.Duration // 單次請求的逾時時間，預設 10 秒
+}
+
+// LinkChainInfo 目的地指向其他短網址時的處理方式
+type LinkChainInfo struct {
+	MaxDepth int      // 最多解析的短網址層數，預設 3
+	Flatten  bool     // 是否將目的地改為導向鏈最後的網址
+	Hosts    []string // RDDomain 與短網址使用的 host 以外，其他同樣導向到 redirector 的 host
+}
+
+// CodeGenInfo 自動產生短網址的方式
+type CodeGenInfo struct {
+	Strategy string // hashids(預設) 以計數器搭配 hashids 產生、random 隨機產生
+	Alphabet string // 使用的字元，為空時 hashids 使用 hashids 的預設字元，random 使用 base62
+	Length   int    // hashids 為最短長度，預設 5；random 為固定長度，預設 7
+}
 
 // LSCfgInfo Link Service Config
 type LSCfgInfo struct {
 	common.BaseCfgInfo `mapstructure:",squash"`
+
+	URLPolicy   URLPolicyInfo
+	HealthCheck HealthCheckInfo
+	LinkChain   LinkChainInfo
+
+	CodeGen          CodeGenInfo
+	HostCodeGen      map[string]CodeGenInfo // 指定 host 使用的產生方式，未指定的 host 使用 CodeGen
+	CounterBlockSize int64                  // hashids 每次向資料庫租用的計數器數量，預設 100
+
+	ReservedWords []string // 不能被使用為客製化短網址的字詞
 }
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+
+	"URLS/internal/common"
+	"URLS/link/models"
+	"URLS/link/pkg/urlpolicy"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const blocklistAddMaxLen = 100
+
+// destArgumentCheck 檢查目的地網址的格式以及是否符合網址規則
+func (lc *LinkController) destArgumentCheck(dest string) (err error) {
+	u, err := url.ParseRequestURI(dest)
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "destination link is not a valid url")
+		return
+	}
+	if err = lc.urlPolicy.Check(u); err != nil {
+		err = status.Error(codes.InvalidArgument, err.Error())
+		return
+	}
+
+	return nil
+}
+
+// blocklistRefresh 從資料庫讀取執行期間的封鎖網域
+func (lc *LinkController) blocklistRefresh(ctx context.Context) (err error) {
+	list, err := models.BlockDomainList(ctx)
+	if err != nil {
+		return
+	}
+
+	domains := make([]string, 0, len(list))
+	for _, info := range list {
+		domains = append(domains, info.Domain)
+	}
+	lc.urlPolicy.SetRuntimeBlocklist(domains)
+
+	return nil
+}
+
+// managerRequestGet 取得發送請求的 user 資料，並確認其為管理員
+func (lc *LinkController) managerRequestGet(ctx context.Context) (userInfo *common.UserInfo, err error) {
+	userInfo, err = lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	if !userInfo.IsManager {
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+
+	return
+}
+
+func (lc *LinkController) BlocklistGet(ctx context.Context, req *linkPB.BlocklistGetRequest) (resp *linkPB.BlocklistGetResponse, err error) {
+	_, err = lc.managerRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	list, err := models.BlockDomainList(ctx)
+	if err != nil {
+		return
+	}
+
+	pbList := make([]*linkPB.BlockDomainInfo, 0, len(list))
+	for _, info := range list {
+		pbList = append(pbList, &linkPB.BlockDomainInfo{
+			Domain:       info.Domain,
+			CreatorIdHex: info.Creator.Hex(),
+			CreateAt:     timestamppb.New(info.CreateAt),
+		})
+	}
+
+	resp = &linkPB.BlocklistGetResponse{
+		BlockDomainList: pbList,
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) BlocklistAdd(ctx context.Context, req *linkPB.BlocklistAddRequest) (resp *linkPB.BlocklistAddResponse, err error) {
+	// 請求資料檢查
+
+	if len(req.GetDomains()) == 0 {
+		err = status.Error(codes.InvalidArgument, "domains can not be empty")
+		return
+	}
+	if len(req.GetDomains()) > blocklistAddMaxLen {
+		err = status.Error(codes.InvalidArgument, "too many domains in one request")
+		return
+	}
+	domains := make([]string, 0, len(req.GetDomains()))
+	for _, domain := range req.GetDomains() {
+		entry := urlpolicy.NormalizeEntry(domain)
+		if entry == "" {
+			err = status.Error(codes.InvalidArgument, "domain \""+domain+"\" is invalid")
+			return
+		}
+		domains = append(domains, entry)
+	}
+
+	userInfo, err := lc.managerRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	err = models.BlockDomainAdd(ctx, domains, userInfo.ID)
+	if err != nil {
+		return
+	}
+	if err = lc.blocklistRefresh(ctx); err != nil {
+		return
+	}
+
+	resp = &linkPB.BlocklistAddResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) BlocklistRemove(ctx context.Context,
+	req *linkPB.BlocklistRemoveRequest) (resp *linkPB.BlocklistRemoveResponse, err error) {
+	domain := urlpolicy.NormalizeEntry(req.GetDomain())
+	if domain == "" {
+		err = status.Error(codes.InvalidArgument, "domain is invalid")
+		return
+	}
+
+	_, err = lc.managerRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	exist, err := models.BlockDomainRemove(ctx, domain)
+	if err != nil {
+		return
+	} else if !exist {
+		err = status.Error(codes.NotFound, "domain was not found")
+		return
+	}
+	if err = lc.blocklistRefresh(ctx); err != nil {
+		return
+	}
+
+	resp = &linkPB.BlocklistRemoveResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"URLS/link/models"
+	"URLS/link/pkg/prefixpath"
+	"URLS/link/pkg/shortcode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultLinkChainMaxDepth = 3
+
+// rdHostsRefresh 從資料庫讀取所有導向到 redirector 的 host
+func (lc *LinkController) rdHostsRefresh(ctx context.Context) (err error) {
+	linkHosts, err := models.LinkHostList(ctx)
+	if err != nil {
+		return
+	}
+
+	hosts := make(map[string]struct{}, len(linkHosts)+len(lc.cfg.LinkChain.Hosts)+1)
+	hosts[strings.ToLower(lc.cfg.RDDomain)] = struct{}{}
+	for _, h := range lc.cfg.LinkChain.Hosts {
+		hosts[strings.ToLower(h)] = struct{}{}
+	}
+	for _, h := range linkHosts {
+		hosts[strings.ToLower(h)] = struct{}{}
+	}
+	lc.rdHosts.Store(&hosts)
+
+	return nil
+}
+
+// isRDHost host 是否會導向到 redirector
+func (lc *LinkController) isRDHost(host string) bool {
+	hosts := lc.rdHosts.Load()
+	if hosts == nil {
+		return false
+	}
+	_, exist := (*hosts)[strings.ToLower(host)]
+	return exist
+}
+
+// shortFromRDURL 如果網址指向 redirector，回傳其對應的 (short, host)
+func (lc *LinkController) shortFromRDURL(u *url.URL) (short, host string, isRD bool) {
+	switch {
+	case lc.isRDHost(u.Host):
+		host = u.Host
+	case lc.isRDHost(u.Hostname()):
+		host = u.Hostname()
+	default:
+		return "", "", false
+	}
+	if strings.EqualFold(host, lc.cfg.RDDomain) {
+		host = ""
+	}
+
+	return strings.TrimPrefix(u.Path, "/"), host, true
+}
+
+// destChainResolve 解析目的地是否指向其他短網址，並回傳導向鏈最後的網址
+//
+// 目的地指向不存在、已刪除的短網址，或是導向鏈形成迴圈、超過設定的層數時會回傳錯誤，
+// viaPrefix 為導向鏈中是否經過前綴短網址
+func (lc *LinkController) destChainResolve(ctx context.Context, dest string) (
+	finalDest string, depth int, viaPrefix bool, err error) {
+	maxDepth := lc.cfg.LinkChain.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultLinkChainMaxDepth
+	}
+
+	visited := make(map[string]struct{}, maxDepth)
+	finalDest = dest
+	for {
+		u, parseErr := url.Parse(finalDest)
+		if parseErr != nil {
+			err = status.Error(codes.InvalidArgument, "destination link is not a valid url")
+			return
+		}
+
+		short, host, isRD := lc.shortFromRDURL(u)
+		if !isRD || short == "" {
+			// 不是指向短網址的網址
+			return finalDest, depth, viaPrefix, nil
+		}
+
+		if depth >= maxDepth {
+			err = status.Error(codes.InvalidArgument,
+				"destination redirect chain is longer than "+strconv.Itoa(maxDepth))
+			return
+		}
+
+		// 與 redirector 相同，先以完整路徑查詢，查詢不到時再以第一段路徑查詢前綴短網址
+		var link *models.LinkInfo
+		var exist bool
+		var restPath string
+		var prefixHit bool
+		link, exist, err = lc.linkFindByRDShort(ctx, short, host)
+		if err == nil && !exist {
+			if first, rest, ok := prefixpath.Split(short); ok {
+				link, exist, err = lc.linkFindByRDShort(ctx, first, host)
+				if exist && link.Type != models.LTPrefix {
+					exist = false
+				}
+				restPath, prefixHit = rest, true
+			}
+		}
+		if err != nil {
+			return
+		} else if !exist || link.Deleted {
+			err = status.Error(codes.InvalidArgument, "destination points to a short link that does not exist")
+			return
+		}
+
+		key := link.Short + "/" + host
+		if _, exist := visited[key]; exist {
+			err = status.Error(codes.InvalidArgument, "destination forms a redirect loop")
+			return
+		}
+		visited[key] = struct{}{}
+
+		depth++
+		finalDest = link.FullDest()
+		if prefixHit {
+			viaPrefix = true
+			if restPath != "" {
+				finalDest = prefixpath.Append(finalDest, restPath)
+			}
+		}
+	}
+}
+
+// linkFindByRDShort 以 redirector 相同的方式查詢短網址，查詢不到時改用正規化後的短網址查詢
+func (lc *LinkController) linkFindByRDShort(ctx context.Context, short, host string) (
+	link *models.LinkInfo, exist bool, err error) {
+	link, exist, err = models.LinkFindByShort(ctx, short, host)
+	if err != nil || exist {
+		return
+	}
+	if normalized := shortcode.Normalize(short, lc.caseFold); normalized != short {
+		return models.LinkFindByShort(ctx, normalized, host)
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"URLS/link/configs"
+	"URLS/link/models"
+	"URLS/link/pkg/codegen"
+)
+
+// newCodeGenerator 根據設定建立短網址產生器，hashids 使用 counter 產生的值
+func newCodeGenerator(ctx context.Context, info configs.CodeGenInfo,
+	counter codegen.CounterFunc) (gen codegen.Generator, err error) {
+	strategy, convOK := codegen.StrategyFromString(info.Strategy)
+	if !convOK {
+		err = fmt.Errorf("unknow short link generate strategy \"%s\"", info.Strategy)
+		return
+	}
+
+	switch strategy {
+	case codegen.StrategyRandom:
+		return codegen.NewRandom(info.Alphabet, info.Length)
+	default:
+		// 使用相同的 slat 與 counter，預設設定下產生的短網址與先前相同
+		slat, err := models.HashIDSlatGet(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return codegen.NewHashIDs(counter, slat, info.Alphabet, info.Length)
+	}
+}
+
+// codeGenerator 回傳 host 使用的短網址產生器
+func (lc *LinkController) codeGenerator(host string) codegen.Generator {
+	if gen, exist := lc.hostCodeGen[host]; exist {
+		return gen
+	}
+	return lc.codeGen
+}
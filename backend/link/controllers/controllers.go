@@ -3,10 +3,18 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"URLS/internal/common"
 	"URLS/link/configs"
 	"URLS/link/models"
+	"URLS/link/pkg/codegen"
+	"URLS/link/pkg/counterlease"
+	"URLS/link/pkg/linkcheck"
+	"URLS/link/pkg/shortcode"
+	"URLS/link/pkg/urlpolicy"
 	linkPB "URLS/proto/gen/go/link/v1"
 	rdModels "URLS/redirector/models"
 
@@ -15,15 +23,32 @@ import (
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+// refreshInterval 重新從資料庫讀取快取資料的間隔
+const refreshInterval = time.Minute
+
 type LinkController struct {
 	*common.BaseController
 	linkPB.UnimplementedLinkServiceServer
 
-	cfg     *configs.LSCfgInfo
-	redisDB *redis.Client
+	cfg         *configs.LSCfgInfo
+	redisDB     *redis.Client
+	urlPolicy   *urlpolicy.Policy
+	linkChecker *linkcheck.Checker
+	caseFold    shortcode.CaseFold
+
+	codeGen     codegen.Generator            // 預設的短網址產生方式
+	hostCodeGen map[string]codegen.Generator // 指定 host 的短網址產生方式
+
+	rdHosts atomic.Pointer[map[string]struct{}] // 導向到 redirector 的所有 host
 }
 
 func NewLinkController(cfgInfo *configs.LSCfgInfo, logger *zap.Logger) (uc *LinkController, err error) {
+	caseFold, convOK := shortcode.CaseFoldFromString(cfgInfo.ShortCode.CaseFold)
+	if !convOK {
+		err = fmt.Errorf("unknow short code case fold \"%s\"", cfgInfo.ShortCode.CaseFold)
+		return
+	}
+
 	bc, err := common.NewBaseController(&cfgInfo.BaseCfgInfo, logger)
 	if err != nil {
 		return
@@ -52,22 +77,97 @@ func NewLinkController(cfgInfo *configs.LSCfgInfo, logger *zap.Logger) (uc *Link
 
 	// init models
 
-	err = models.InitModels(bgCtx, bc.MgoDB, bc.Logger)
+	err = models.InitModels(bgCtx, bc.MgoClient, bc.MgoDB, bc.Logger)
 	if err != nil {
 		err = fmt.Errorf("InitIndex failed, err=%s", err)
 		return
 	}
 	rdModels.InitModels(rClient, logger)
 
+	err = models.ReservedWordSeed(bgCtx, builtinReservedWords(), cfgInfo.ReservedWords)
+	if err != nil {
+		err = fmt.Errorf("ReservedWordSeed failed, err=%s", err)
+		return
+	}
+
+	// init url policy
+
+	urlPolicy := urlpolicy.New(urlpolicy.Options{
+		AllowSchemes:        cfgInfo.URLPolicy.AllowSchemes,
+		AllowIPLiteral:      cfgInfo.URLPolicy.AllowIPLiteral,
+		AllowPrivateNetwork: cfgInfo.URLPolicy.AllowPrivateNetwork,
+	})
+	if cfgInfo.URLPolicy.BlocklistPath != "" {
+		err = urlPolicy.LoadBlocklistFile(cfgInfo.URLPolicy.BlocklistPath)
+		if err != nil {
+			err = fmt.Errorf("load blocklist file failed, err=%s", err)
+			return
+		}
+	}
+
+	// init short link generator
+
+	counter := counterlease.New(models.LinkCounterLease, cfgInfo.CounterBlockSize)
+	codeGen, err := newCodeGenerator(bgCtx, cfgInfo.CodeGen, counter.Next)
+	if err != nil {
+		err = fmt.Errorf("init short link generator failed, err=%s", err)
+		return
+	}
+	hostCodeGen := make(map[string]codegen.Generator, len(cfgInfo.HostCodeGen))
+	for host, info := range cfgInfo.HostCodeGen {
+		hostCodeGen[strings.ToLower(host)], err = newCodeGenerator(bgCtx, info, counter.Next)
+		if err != nil {
+			err = fmt.Errorf("init short link generator of host \"%s\" failed, err=%s", host, err)
+			return
+		}
+	}
+
 	uc = &LinkController{
 		BaseController: bc,
 		cfg:            cfgInfo,
 		redisDB:        rClient,
+		urlPolicy:      urlPolicy,
+		caseFold:       caseFold,
+		codeGen:        codeGen,
+		hostCodeGen:    hostCodeGen,
+	}
+
+	refreshFuncs := []func(context.Context) error{
+		uc.blocklistRefresh,
+		uc.rdHostsRefresh,
+	}
+	for _, f := range refreshFuncs {
+		if err = f(bgCtx); err != nil {
+			return nil, err
+		}
+	}
+	go uc.refreshLoop(refreshFuncs)
+
+	go uc.outboxLoop()
+	go uc.rdSyncLoop()
+
+	uc.linkChecker = uc.newLinkChecker()
+	if cfgInfo.HealthCheck.Enable {
+		go uc.healthCheckLoop()
 	}
 
 	return uc, nil
 }
 
+// refreshLoop 定期執行 refreshFuncs，讓多個 link service 從資料庫讀取的快取資料保持一致
+func (lc *LinkController) refreshLoop(refreshFuncs []func(context.Context) error) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, f := range refreshFuncs {
+			if err := f(context.Background()); err != nil {
+				lc.Logger.Error("refresh cached data failed", zap.Error(err))
+			}
+		}
+	}
+}
+
 // Ping 用來測試服務是否依然在線
 //
 // GET /ping
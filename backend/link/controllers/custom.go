@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"URLS/link/models"
+	"URLS/link/pkg/shortcode"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	customSuggestionNum = 5
+	// customTitleTimeout 讀取目的地標題的最長時間，逾時則只使用後綴產生建議
+	customTitleTimeout = 3 * time.Second
+)
+
+const (
+	customReasonTaken    = "taken"
+	customReasonReserved = "reserved"
+)
+
+// hostArgumentCheck 檢查 host 是否導向到 redirector，RDDomain 會被轉換為預設的空 host
+func (lc *LinkController) hostArgumentCheck(host string) (string, error) {
+	host = strings.ToLower(host)
+	if host == "" || host == strings.ToLower(lc.cfg.RDDomain) {
+		return "", nil
+	}
+	if !lc.isRDHost(host) {
+		return "", status.Error(codes.InvalidArgument, "host is not a short link domain")
+	}
+
+	return host, nil
+}
+
+// customUnavailable 回傳 customs 中無法使用的短網址與原因
+func customUnavailable(ctx context.Context, host string, customs []string) (reasons map[string]string, err error) {
+	reserved, err := models.ReservedWordsExist(ctx, customs)
+	if err != nil {
+		return
+	}
+	taken, err := models.LinkShortsExist(ctx, host, customs)
+	if err != nil {
+		return
+	}
+
+	reasons = make(map[string]string, len(reserved)+len(taken))
+	for custom := range taken {
+		reasons[custom] = customReasonTaken
+	}
+	for custom := range reserved {
+		reasons[custom] = customReasonReserved
+	}
+	return reasons, nil
+}
+
+// customSuggestions 根據 custom 與目的地標題回傳可以使用的短網址
+func (lc *LinkController) customSuggestions(ctx context.Context, host, custom, dest string) (suggestions []string, err error) {
+	var titleWords []string
+	if dest != "" {
+		titleCtx, cancel := context.WithTimeout(ctx, customTitleTimeout)
+		title, titleErr := lc.linkChecker.Title(titleCtx, dest)
+		cancel()
+		if titleErr != nil {
+			lc.Logger.Debug("get destination title failed", zap.String("dest", dest), zap.Error(titleErr))
+		}
+		titleWords = shortcode.TitleWords(title)
+	}
+
+	candidates := make([]string, 0, len(titleWords))
+	for _, c := range shortcode.Suggestions(custom, titleWords) {
+		c = shortcode.Normalize(c, lc.caseFold)
+		if !shortcode.IsConfusable(c) {
+			candidates = append(candidates, c)
+		}
+	}
+
+	unavailable, err := customUnavailable(ctx, host, candidates)
+	if err != nil {
+		return
+	}
+	suggestions = make([]string, 0, customSuggestionNum)
+	for _, c := range candidates {
+		if _, exist := unavailable[c]; exist {
+			continue
+		}
+		suggestions = append(suggestions, c)
+		if len(suggestions) == customSuggestionNum {
+			break
+		}
+	}
+
+	return suggestions, nil
+}
+
+func (lc *LinkController) CustomCheck(ctx context.Context, req *linkPB.CustomCheckRequest) (resp *linkPB.CustomCheckResponse, err error) {
+	// 請求資料檢查
+
+	if req.GetCustom() == "" {
+		err = status.Error(codes.InvalidArgument, "custom can not be empty")
+		return
+	}
+	custom, err := lc.customArgumentCheck(req.GetCustom())
+	if err != nil {
+		return
+	}
+	host, err := lc.hostArgumentCheck(req.GetHost())
+	if err != nil {
+		return
+	}
+	if req.GetDest() != "" {
+		if err = lc.destArgumentCheck(req.GetDest()); err != nil {
+			return
+		}
+	}
+
+	_, err = lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	unavailable, err := customUnavailable(ctx, host, []string{custom})
+	if err != nil {
+		return
+	}
+	reason, exist := unavailable[custom]
+	if !exist {
+		resp = &linkPB.CustomCheckResponse{
+			Custom:    custom,
+			Available: true,
+		}
+		return resp, nil
+	}
+
+	suggestions, err := lc.customSuggestions(ctx, host, custom, req.GetDest())
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.CustomCheckResponse{
+		Custom:      custom,
+		Available:   false,
+		Reason:      reason,
+		Suggestions: suggestions,
+	}
+	return resp, nil
+}
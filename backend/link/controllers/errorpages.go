@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"URLS/internal/common"
+	"URLS/link/models"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errorPageTemplateMaxLen 錯誤頁面 HTML 的最大長度
+const errorPageTemplateMaxLen = 32 * 1024
+
+// errorPageScopeArgumentGet 解析錯誤頁面的範圍，為 0 時使用 EPScopeUser，EPScopeHost 需要是管理員
+func (lc *LinkController) errorPageScopeArgumentGet(scopeInt32 int32,
+	userInfo *common.UserInfo) (scope models.ErrorPageScope, err error) {
+	if scopeInt32 == 0 {
+		return models.EPScopeUser, nil
+	}
+
+	scope, convOK := models.ErrorPageScopeFromInteger(scopeInt32)
+	if !convOK {
+		err = status.Error(codes.InvalidArgument, "unknow error page scope")
+		return
+	}
+	if scope == models.EPScopeHost && !userInfo.IsManager {
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+
+	return scope, nil
+}
+
+// errorPageContentArgumentCheck 檢查錯誤頁面的內容，fallbackURL 與 template 只能設定其中一個
+func (lc *LinkController) errorPageContentArgumentCheck(fallbackURL, template string) (err error) {
+	switch {
+	case fallbackURL == "" && template == "":
+		err = status.Error(codes.InvalidArgument, "fallback url or template must be set")
+		return
+	case fallbackURL != "" && template != "":
+		err = status.Error(codes.InvalidArgument, "fallback url and template can not be set at the same time")
+		return
+	case template != "":
+		if len(template) > errorPageTemplateMaxLen {
+			err = status.Error(codes.InvalidArgument, "length of template is greater than "+strconv.Itoa(errorPageTemplateMaxLen))
+			return
+		}
+		return nil
+	}
+
+	if err = lc.destArgumentCheck(fallbackURL); err != nil {
+		return
+	}
+	// 導向到短網址可能會再次導向到錯誤頁面
+	if u, _ := url.ParseRequestURI(fallbackURL); lc.isRDHost(u.Host) || lc.isRDHost(u.Hostname()) {
+		err = status.Error(codes.InvalidArgument, "fallback url can not be a short link")
+		return
+	}
+
+	return nil
+}
+
+func mErrorPageInfoToPBErrorPageInfo(page *models.ErrorPageInfo) *linkPB.ErrorPageInfo {
+	return &linkPB.ErrorPageInfo{
+		IdHex:       page.Id.Hex(),
+		Scope:       int32(page.Scope),
+		Host:        page.Host,
+		State:       int32(page.State),
+		FallbackUrl: page.FallbackURL,
+		Template:    page.Template,
+		CreateAt:    timestamppb.New(page.CreateAt),
+	}
+}
+
+func (lc *LinkController) ErrorPageSet(ctx context.Context,
+	req *linkPB.ErrorPageSetRequest) (resp *linkPB.ErrorPageSetResponse, err error) {
+	// 請求資料檢查
+
+	state, convOK := models.LinkStateFromInteger(req.GetState())
+	if !convOK {
+		err = status.Error(codes.InvalidArgument, "unknow link state")
+		return
+	}
+	if err = lc.errorPageContentArgumentCheck(req.GetFallbackUrl(), req.GetTemplate()); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	scope, err := lc.errorPageScopeArgumentGet(req.GetScope(), userInfo)
+	if err != nil {
+		return
+	}
+
+	page := &models.ErrorPageInfo{
+		Scope:       scope,
+		State:       state,
+		FallbackURL: req.GetFallbackUrl(),
+		Template:    req.GetTemplate(),
+	}
+	switch scope {
+	case models.EPScopeUser:
+		if state == models.LSNotFound {
+			err = status.Error(codes.InvalidArgument, "not found page can only be set for a domain")
+			return
+		}
+		// 使用者的 HTML 會以短網址網域的 origin 回應，只允許管理員設定
+		if page.Template != "" {
+			err = status.Error(codes.InvalidArgument, "template can only be set for a domain")
+			return
+		}
+		page.Owner = userInfo.ID
+	case models.EPScopeHost:
+		page.Host, err = lc.hostArgumentCheck(req.GetHost())
+		if err != nil {
+			return
+		}
+	}
+
+	if err = models.ErrorPageSet(ctx, page); err != nil {
+		return
+	}
+
+	resp = &linkPB.ErrorPageSetResponse{
+		ErrorPageInfo: mErrorPageInfoToPBErrorPageInfo(page),
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) ErrorPageList(ctx context.Context,
+	req *linkPB.ErrorPageListRequest) (resp *linkPB.ErrorPageListResponse, err error) {
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	scope, err := lc.errorPageScopeArgumentGet(req.GetScope(), userInfo)
+	if err != nil {
+		return
+	}
+
+	var list []*models.ErrorPageInfo
+	if scope == models.EPScopeHost {
+		list, err = models.ErrorPageListByHost(ctx)
+	} else {
+		list, err = models.ErrorPageListByOwner(ctx, userInfo.ID)
+	}
+	if err != nil {
+		return
+	}
+
+	pbList := make([]*linkPB.ErrorPageInfo, 0, len(list))
+	for _, page := range list {
+		pbList = append(pbList, mErrorPageInfoToPBErrorPageInfo(page))
+	}
+
+	resp = &linkPB.ErrorPageListResponse{
+		ErrorPageInfoList: pbList,
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) ErrorPageDelete(ctx context.Context,
+	req *linkPB.ErrorPageDeleteRequest) (resp *linkPB.ErrorPageDeleteResponse, err error) {
+	id, err := primitive.ObjectIDFromHex(req.GetErrorPageIdHex())
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "error page id format is invalid")
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	page, exist, err := models.ErrorPageFindByID(ctx, id)
+	if err != nil {
+		return
+	}
+	switch {
+	case !exist, page.Scope == models.EPScopeUser && page.Owner != userInfo.ID:
+		err = status.Error(codes.NotFound, "error page was not found")
+		return
+	case page.Scope == models.EPScopeHost && !userInfo.IsManager:
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+
+	if err = page.Delete(ctx); err != nil {
+		return
+	}
+
+	resp = &linkPB.ErrorPageDeleteResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"URLS/link/models"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const folderNameMaxLen = 30
+
+// folderMaxNum 每個使用者最多可以建立的資料夾數量
+const folderMaxNum = 1000
+
+// folderMaxDepth 資料夾最多的層數
+const folderMaxDepth = 10
+
+// folderNameArgumentCheck 檢查資料夾名稱是否有效
+func folderNameArgumentCheck(name string) (err error) {
+	if strings.TrimSpace(name) == "" {
+		err = status.Error(codes.InvalidArgument, "folder name can not be empty")
+		return
+	}
+	if len(name) > folderNameMaxLen {
+		err = status.Error(codes.InvalidArgument, "length of folder name is greater than "+strconv.Itoa(folderNameMaxLen))
+		return
+	}
+
+	return nil
+}
+
+// folderIDArgumentParse 解析 folder id，空字串時回傳空 id
+func folderIDArgumentParse(idHex string) (id primitive.ObjectID, err error) {
+	if idHex == "" {
+		return primitive.NilObjectID, nil
+	}
+	id, err = primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "folder id format is invalid")
+		return
+	}
+
+	return id, nil
+}
+
+// folderArgumentGet 取得 owner 的資料夾，idHex 為空字串時回傳 nil
+func folderArgumentGet(ctx context.Context, owner primitive.ObjectID, idHex string) (
+	folder *models.FolderInfo, err error) {
+	id, err := folderIDArgumentParse(idHex)
+	if err != nil || id.IsZero() {
+		return
+	}
+
+	folder, exist, err := models.FolderFindByID(ctx, id)
+	if err != nil {
+		return
+	} else if !exist || folder.Owner != owner {
+		err = status.Error(codes.NotFound, "folder was not found")
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// folderID 回傳資料夾的 id，folder 為 nil 時回傳空 id
+func folderID(folder *models.FolderInfo) primitive.ObjectID {
+	if folder == nil {
+		return primitive.NilObjectID
+	}
+	return folder.Id
+}
+
+// folderTree 使用者所有資料夾的上下層關係
+type folderTree map[primitive.ObjectID]*models.FolderInfo
+
+func newFolderTree(list []*models.FolderInfo) folderTree {
+	tree := make(folderTree, len(list))
+	for _, folder := range list {
+		tree[folder.Id] = folder
+	}
+	return tree
+}
+
+// ancestors 回傳 id 本身與其所有上層資料夾的 id
+func (t folderTree) ancestors(id primitive.ObjectID) []primitive.ObjectID {
+	list := make([]primitive.ObjectID, 0, folderMaxDepth)
+	for folder, exist := t[id]; exist && len(list) <= folderMaxDepth; folder, exist = t[folder.Parent] {
+		list = append(list, folder.Id)
+	}
+	return list
+}
+
+// descendants 回傳 id 本身與其所有子資料夾的 id
+func (t folderTree) descendants(id primitive.ObjectID) []primitive.ObjectID {
+	children := make(map[primitive.ObjectID][]primitive.ObjectID, len(t))
+	for _, folder := range t {
+		if !folder.Parent.IsZero() {
+			children[folder.Parent] = append(children[folder.Parent], folder.Id)
+		}
+	}
+
+	list := []primitive.ObjectID{id}
+	for i := 0; i < len(list); i++ {
+		list = append(list, children[list[i]]...)
+	}
+	return list
+}
+
+// height 回傳 id 與其子資料夾的層數
+func (t folderTree) height(id primitive.ObjectID) int {
+	var maxDepth int
+	rootDepth := len(t.ancestors(id))
+	for _, child := range t.descendants(id) {
+		if depth := len(t.ancestors(child)) - rootDepth + 1; depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	return maxDepth
+}
+
+func mFolderInfoToPBFolderInfo(folder *models.FolderInfo, clicks models.FolderClicks) *linkPB.FolderInfo {
+	pbInfo := &linkPB.FolderInfo{
+		IdHex:       folder.Id.Hex(),
+		Name:        folder.Name,
+		CreateAt:    timestamppb.New(folder.CreateAt),
+		LinkNum:     clicks.LinkNum,
+		TotalClicks: clicks.TotalClicks,
+	}
+	if !folder.Parent.IsZero() {
+		pbInfo.ParentIdHex = folder.Parent.Hex()
+	}
+	return pbInfo
+}
+
+func (lc *LinkController) FolderCreate(ctx context.Context,
+	req *linkPB.FolderCreateRequest) (resp *linkPB.FolderCreateResponse, err error) {
+	// 請求資料檢查
+
+	if err = folderNameArgumentCheck(req.GetName()); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	parent, err := folderArgumentGet(ctx, userInfo.ID, req.GetParentIdHex())
+	if err != nil {
+		return
+	}
+
+	list, err := models.FolderListByOwner(ctx, userInfo.ID)
+	if err != nil {
+		return
+	}
+	if len(list) >= folderMaxNum {
+		err = status.Error(codes.ResourceExhausted, "the number of folders has reached the limit")
+		return
+	}
+	if parent != nil && len(newFolderTree(list).ancestors(parent.Id)) >= folderMaxDepth {
+		err = status.Error(codes.InvalidArgument, "folders can not be nested more than "+strconv.Itoa(folderMaxDepth)+" levels")
+		return
+	}
+
+	folder, err := models.FolderCreate(ctx, userInfo.ID, req.GetName(), folderID(parent))
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.FolderCreateResponse{
+		FolderInfo: mFolderInfoToPBFolderInfo(folder, models.FolderClicks{}),
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) FolderList(ctx context.Context,
+	req *linkPB.FolderListRequest) (resp *linkPB.FolderListResponse, err error) {
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	list, err := models.FolderListByOwner(ctx, userInfo.ID)
+	if err != nil {
+		return
+	}
+	clicks, err := models.FolderClicksAggreByOwner(ctx, userInfo.ID)
+	if err != nil {
+		return
+	}
+
+	// 將每個資料夾的統計加到所有上層資料夾中
+	tree := newFolderTree(list)
+	totalClicks := make(map[primitive.ObjectID]models.FolderClicks, len(list))
+	for id, c := range clicks {
+		for _, ancestor := range tree.ancestors(id) {
+			total := totalClicks[ancestor]
+			total.LinkNum += c.LinkNum
+			total.TotalClicks += c.TotalClicks
+			totalClicks[ancestor] = total
+		}
+	}
+
+	pbList := make([]*linkPB.FolderInfo, 0, len(list))
+	for _, folder := range list {
+		pbList = append(pbList, mFolderInfoToPBFolderInfo(folder, totalClicks[folder.Id]))
+	}
+
+	resp = &linkPB.FolderListResponse{
+		FolderInfoList: pbList,
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) FolderPatch(ctx context.Context,
+	req *linkPB.FolderPatchRequest) (resp *linkPB.FolderPatchResponse, err error) {
+	// 請求資料檢查
+
+	if !req.GetPatchName() && !req.GetPatchParent() {
+		err = status.Error(codes.InvalidArgument, "no patch field")
+		return
+	}
+	if req.GetPatchName() {
+		if err = folderNameArgumentCheck(req.GetName()); err != nil {
+			return
+		}
+	}
+	if _, err = folderIDArgumentParse(req.GetFolderIdHex()); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	folder, err := folderArgumentGet(ctx, userInfo.ID, req.GetFolderIdHex())
+	if err != nil {
+		return
+	} else if folder == nil {
+		err = status.Error(codes.InvalidArgument, "folder id can not be empty")
+		return
+	}
+
+	if req.GetPatchParent() {
+		var parent *models.FolderInfo
+		parent, err = folderArgumentGet(ctx, userInfo.ID, req.GetParentIdHex())
+		if err != nil {
+			return
+		}
+
+		if parent != nil {
+			var list []*models.FolderInfo
+			list, err = models.FolderListByOwner(ctx, userInfo.ID)
+			if err != nil {
+				return
+			}
+			tree := newFolderTree(list)
+			parentAncestors := tree.ancestors(parent.Id)
+			for _, id := range parentAncestors {
+				if id == folder.Id {
+					err = status.Error(codes.InvalidArgument, "can not move folder into itself or its subfolder")
+					return
+				}
+			}
+			if len(parentAncestors)+tree.height(folder.Id) > folderMaxDepth {
+				err = status.Error(codes.InvalidArgument, "folders can not be nested more than "+strconv.Itoa(folderMaxDepth)+" levels")
+				return
+			}
+		}
+
+		if err = folder.Move(ctx, folderID(parent)); err != nil {
+			return
+		}
+	}
+	if req.GetPatchName() {
+		if err = folder.Rename(ctx, req.GetName()); err != nil {
+			return
+		}
+	}
+
+	resp = &linkPB.FolderPatchResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) FolderDelete(ctx context.Context,
+	req *linkPB.FolderDeleteRequest) (resp *linkPB.FolderDeleteResponse, err error) {
+	if _, err = folderIDArgumentParse(req.GetFolderIdHex()); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	folder, err := folderArgumentGet(ctx, userInfo.ID, req.GetFolderIdHex())
+	if err != nil {
+		return
+	} else if folder == nil {
+		err = status.Error(codes.InvalidArgument, "folder id can not be empty")
+		return
+	}
+
+	list, err := models.FolderListByOwner(ctx, userInfo.ID)
+	if err != nil {
+		return
+	}
+	err = models.FolderDelete(ctx, userInfo.ID, newFolderTree(list).descendants(folder.Id))
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.FolderDeleteResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"URLS/link/models"
+	"URLS/link/pkg/linkcheck"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultHealthCheckInterval    = 24 * time.Hour
+	defaultHealthCheckConcurrency = 20
+
+	// healthCheckScanInterval 尋找需要檢查的短網址的間隔
+	healthCheckScanInterval = time.Minute
+)
+
+// newLinkChecker 根據設定建立目的地檢查器，重新導向的網址同樣需要符合網址規則
+func (lc *LinkController) newLinkChecker() *linkcheck.Checker {
+	cfg := lc.cfg.HealthCheck
+
+	return linkcheck.New(linkcheck.Options{
+		RatePerSec:         cfg.RatePerSec,
+		PerHostConcurrency: cfg.PerHostConcurrency,
+		Timeout:            cfg.Timeout,
+		RedirectCheck: func(u *url.URL) error {
+			return lc.urlPolicy.Check(u)
+		},
+		DialCheck: lc.urlPolicy.CheckIP,
+	})
+}
+
+func (lc *LinkController) healthCheckLoop() {
+	interval := lc.cfg.HealthCheck.Interval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(healthCheckScanInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		lc.healthCheckRound(context.Background(), interval)
+	}
+}
+
+// healthCheckRound 檢查所有超過 interval 未被檢查的短網址
+func (lc *LinkController) healthCheckRound(ctx context.Context, interval time.Duration) {
+	concurrency := lc.cfg.HealthCheck.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultHealthCheckConcurrency
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		link, exist, err := models.LinkHealthClaim(ctx, time.Now().Add(-interval))
+		if err != nil || !exist {
+			return
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			lc.linkHealthCheck(ctx, link)
+		}()
+	}
+}
+
+func (lc *LinkController) linkHealthCheck(ctx context.Context, link *models.LinkInfo) {
+	res := lc.linkChecker.Check(ctx, link.FullDest())
+	if res.Err != nil {
+		lc.Logger.Debug("link health check failed",
+			zap.String("link id", link.Id.Hex()), zap.Error(res.Err))
+	}
+
+	_ = link.HealthUpdate(ctx, &models.LinkHealthInfo{
+		StatusCode: res.StatusCode,
+		LatencyMS:  res.Latency.Milliseconds(),
+		Broken:     res.Broken(),
+		CheckAt:    time.Now(),
+	})
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"URLS/internal/common"
+	"URLS/link/models"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// historyValueConv 將資料庫中的值轉換為 structpb 可以使用的型態
+func historyValueConv(v interface{}) interface{} {
+	switch v := v.(type) {
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().Format(time.RFC3339)
+	case primitive.A:
+		list := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			list = append(list, historyValueConv(e))
+		}
+		return list
+	case []string:
+		list := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			list = append(list, e)
+		}
+		return list
+	case bson.M:
+		return historyValuesConv(v)
+	case bson.D:
+		return historyValuesConv(v.Map())
+	default:
+		return v
+	}
+}
+
+func historyValuesConv(values bson.M) map[string]interface{} {
+	m := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		m[k] = historyValueConv(v)
+	}
+	return m
+}
+
+func (lc *LinkController) mHistoryInfoToPBHistoryInfo(history *models.LinkHistoryInfo) (*linkPB.LinkHistoryInfo, error) {
+	pbInfo := &linkPB.LinkHistoryInfo{
+		Action:     string(history.Action),
+		ActorIdHex: history.Actor.Hex(),
+		CreateAt:   timestamppb.New(history.CreateAt),
+	}
+
+	var err error
+	if history.Before != nil {
+		if pbInfo.Before, err = structpb.NewStruct(historyValuesConv(history.Before)); err != nil {
+			lc.Logger.Error("convert history before values failed", zap.Error(err))
+			return nil, common.GRPCErrInternal
+		}
+	}
+	if history.After != nil {
+		if pbInfo.After, err = structpb.NewStruct(historyValuesConv(history.After)); err != nil {
+			lc.Logger.Error("convert history after values failed", zap.Error(err))
+			return nil, common.GRPCErrInternal
+		}
+	}
+	return pbInfo, nil
+}
+
+func (lc *LinkController) LinkRestore(ctx context.Context, req *linkPB.LinkRestoreRequest) (resp *linkPB.LinkRestoreResponse, err error) {
+	toRestoreLinkID, err := primitive.ObjectIDFromHex(req.GetLinkIdHex())
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "link id format is invalid")
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	toRestoreLink, exist, err := models.LinkFindByID(ctx, toRestoreLinkID)
+	if err != nil {
+		return
+	} else if !exist {
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+	if err = lc.linkEditPermCheck(ctx, userInfo, toRestoreLink); err != nil {
+		return
+	}
+
+	if toRestoreLink.Deleted {
+		// 目的地可能在刪除後被加入封鎖清單
+		if err = lc.destArgumentCheck(toRestoreLink.Dest); err != nil {
+			return
+		}
+
+		err = toRestoreLink.SetNoDelete(ctx, userInfo.ID)
+		if err != nil {
+			return
+		}
+	}
+	resp = &linkPB.LinkRestoreResponse{
+		Msg: "success",
+	}
+
+	return resp, nil
+}
+
+func (lc *LinkController) LinkHistoryList(ctx context.Context,
+	req *linkPB.LinkHistoryListRequest) (resp *linkPB.LinkHistoryListResponse, err error) {
+	// 請求資料檢查
+
+	linkID, err := primitive.ObjectIDFromHex(req.GetLinkIdHex())
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "link id format is invalid")
+		return
+	}
+	if req.GetPage() == 0 {
+		err = status.Error(codes.InvalidArgument, "page needs to be a value greater than 0")
+		return
+	}
+	if req.GetPageSize() == 0 {
+		err = status.Error(codes.InvalidArgument, "pagesize needs to be a value greater than 0")
+		return
+	}
+	if req.GetPageSize() > pageSizeMax {
+		err = status.Error(codes.InvalidArgument, "the maximum upper limit for pagesize is 30")
+		return
+	}
+
+	// 權限檢查
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	link, exist, err := models.LinkFindByID(ctx, linkID)
+	if err != nil {
+		return
+	} else if !exist {
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+	if err = lc.linkViewPermCheck(ctx, userInfo, link); err != nil {
+		return
+	}
+
+	skip := int64((req.GetPage() - 1) * req.GetPageSize())
+	historyList, err := models.LinkHistoryList(ctx, link.Id, skip, int64(req.GetPageSize()))
+	if err != nil {
+		return
+	}
+
+	pbHistoryList := make([]*linkPB.LinkHistoryInfo, 0, len(historyList))
+	for _, history := range historyList {
+		var pbHistory *linkPB.LinkHistoryInfo
+		if pbHistory, err = lc.mHistoryInfoToPBHistoryInfo(history); err != nil {
+			return
+		}
+		pbHistoryList = append(pbHistoryList, pbHistory)
+	}
+
+	resp = &linkPB.LinkHistoryListResponse{
+		HistoryList: pbHistoryList,
+	}
+	return resp, nil
+}
@@ -2,18 +2,14 @@ package controllers
 
 import (
 	"context"
-	"net/url"
 	"strconv"
-	"unicode"
 
 	"URLS/internal/common"
 	"URLS/link/models"
+	"URLS/link/pkg/shortcode"
 	linkPB "URLS/proto/gen/go/link/v1"
-	userPB "URLS/proto/gen/go/user/v1"
-	rdModels "URLS/redirector/models"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -46,31 +42,75 @@ func tagsArgumentCheck(tagList []string) (err error) {
 	return
 }
 
+// redirectCodeArgumentCheck 檢查導向狀態碼，0 代表使用 redirector 的預設值
+func redirectCodeArgumentCheck(code int32) (err error) {
+	if code != 0 && !models.RedirectCodeValid(int(code)) {
+		err = status.Error(codes.InvalidArgument, "redirect code must be one of 301, 302, 307, 308")
+		return
+	}
+
+	return nil
+}
+
+// customArgumentCheck 將客製化短網址正規化，並檢查其是否有效
+//
+// 回傳的 normalized 為實際儲存與 redirector 查詢時使用的短網址
+func (lc *LinkController) customArgumentCheck(custom string) (normalized string, err error) {
+	if custom == "" {
+		return "", nil
+	}
+
+	normalized = shortcode.Normalize(custom, lc.caseFold)
+	for _, runeValue := range normalized {
+		if !shortcode.IsValidRune(runeValue) {
+			err = status.Error(codes.InvalidArgument, "custom link contains invalid characters")
+			return
+		}
+	}
+	if shortcode.IsConfusable(normalized) {
+		err = status.Error(codes.InvalidArgument, "custom link contains characters that can be confused with other letters")
+		return
+	}
+
+	return normalized, nil
+}
+
 func (lc *LinkController) LinkCreate(ctx context.Context, req *linkPB.LinkCreateRequest) (resp *linkPB.LinkCreateResponse, err error) {
 	// 請求資料檢查
 
-	_, err = url.ParseRequestURI(req.GetDest())
+	linkType := models.LTDirect
+	if req.GetType() != 0 {
+		var convOK bool
+		linkType, convOK = models.LinkTypeFromInteger(req.GetType())
+		if !convOK {
+			err = status.Error(codes.InvalidArgument, "link type is invalid")
+			return
+		}
+	}
+	if err = lc.destArgumentCheck(req.GetDest()); err != nil {
+		return
+	}
+	custom, err := lc.customArgumentCheck(req.GetCustom())
 	if err != nil {
-		err = status.Error(codes.InvalidArgument, "destination link is not a valid url")
 		return
 	}
-	custom := req.GetCustom()
-	if custom != "" {
-		for _, runeValue := range custom {
-			if !unicode.IsLetter(runeValue) && !unicode.IsNumber(runeValue) &&
-				runeValue != rune('-') && runeValue != rune('_') &&
-				!unicode.Is(unicode.Han, runeValue) {
-				err = status.Error(codes.InvalidArgument, "custom link contains invalid characters")
-				return
-			}
+	host, err := lc.hostArgumentCheck(req.GetHost())
+	if err != nil {
+		return
+	}
+	if err = utmArgumentCheck(req.GetUtmInfo()); err != nil {
+		return
+	}
+	if req.GetUtmTemplateIdHex() != "" {
+		if _, err = utmTemplateIDArgumentParse(req.GetUtmTemplateIdHex()); err != nil {
+			return
 		}
 	}
-	if len(req.GetUtmInfo().Source) > utmMaxLen ||
-		len(req.GetUtmInfo().Medium) > utmMaxLen ||
-		len(req.GetUtmInfo().Campaign) > utmMaxLen ||
-		len(req.GetUtmInfo().Term) > utmMaxLen ||
-		len(req.GetUtmInfo().Content) > utmMaxLen {
-		err = status.Error(codes.InvalidArgument, "length of utm is greater than "+strconv.Itoa(utmMaxLen))
+	passthroughInfo, err := passthroughArgumentGet(req.GetPassthrough())
+	if err != nil {
+		return
+	}
+	if err = redirectCodeArgumentCheck(req.GetRedirectCode()); err != nil {
 		return
 	}
 
@@ -81,55 +121,121 @@ func (lc *LinkController) LinkCreate(ctx context.Context, req *linkPB.LinkCreate
 	if err = tagsArgumentCheck(req.GetTags()); err != nil {
 		return
 	}
+	if err = workspaceIDArgumentCheck(req.GetWorkspaceIdHex()); err != nil {
+		return
+	}
+	if req.GetWorkspaceIdHex() != "" && req.GetFolderIdHex() != "" {
+		err = status.Error(codes.InvalidArgument, "folder can not be used with workspace link")
+		return
+	}
 
 	// 使用者身分驗證與剩餘額度確認
+	// 工作區的短網址使用工作區的額度
 
 	userInfo, err := lc.UserRequestGet(ctx)
 	if err != nil {
 		return
 	}
-	if userInfo.NormalLinkUsage >= userInfo.NormalLinkQuota ||
-		custom != "" && userInfo.CustomLinkUsage >= userInfo.CustomLinkQuota {
+	normalUsage, normalQuota := userInfo.NormalLinkUsage, userInfo.NormalLinkQuota
+	customUsage, customQuota := userInfo.CustomLinkUsage, userInfo.CustomLinkQuota
+	var workspace *common.WorkspaceMemberInfo
+	if req.GetWorkspaceIdHex() != "" {
+		workspace, err = lc.workspaceMemberGet(ctx, req.GetWorkspaceIdHex(), userInfo)
+		if err != nil {
+			return
+		} else if !workspace.CanEdit() {
+			err = common.GRPCERRPermissionDenied
+			return
+		}
+		normalUsage, normalQuota = workspace.NormalLinkUsage, workspace.NormalLinkQuota
+		customUsage, customQuota = workspace.CustomLinkUsage, workspace.CustomLinkQuota
+	}
+	if normalUsage >= normalQuota ||
+		custom != "" && customUsage >= customQuota {
 		err = status.Error(codes.ResourceExhausted, "your quota was exceeded")
 		return
 	}
-
+	if custom != "" {
+		if err = customReservedCheck(ctx, custom); err != nil {
+			return
+		}
+	}
+	folder, err := folderArgumentGet(ctx, userInfo.ID, req.GetFolderIdHex())
 	if err != nil {
-		lc.Logger.Error("lc.SrvcConn.User.LinkTagsAdd failed", zap.Error(err))
-		err = common.GRPCErrInternal
 		return
 	}
+	utm := models.UTMInfoFromPB(req.GetUtmInfo())
+	if req.GetUtmTemplateIdHex() != "" {
+		var template *models.UTMTemplateInfo
+		template, err = lc.utmTemplateArgumentGet(ctx, userInfo, req.GetUtmTemplateIdHex(), false)
+		if err != nil {
+			return
+		}
+		utm = template.UTMInfo.Override(utm)
+	}
 
-	// 資料庫添加資料
+	// 目的地為其他短網址時，避免形成迴圈
 
-	newLink, err := models.LinkCreate(ctx,
-		custom, "", req.GetDest(), models.UTMInfoFromPB(req.GetUtmInfo()), userInfo.ID, req.GetNote(), req.GetTags())
+	dest, chainDepth, viaPrefix, err := lc.destChainResolve(ctx, req.GetDest())
 	if err != nil {
 		return
 	}
-
-	err = rdModels.LinkAdd(ctx, newLink)
-	if err != nil {
-		_ = newLink.Delete(ctx)
+	if linkType == models.LTPrefix && viaPrefix {
+		// 前綴短網址每次導向都會加上路徑，指向其他前綴短網址時網址會不斷變長
+		err = status.Error(codes.InvalidArgument, "prefix link can not point to another prefix link")
 		return
 	}
+	if chainDepth == 0 || !lc.cfg.LinkChain.Flatten {
+		dest = req.GetDest()
+	}
+
+	// 資料庫添加資料
 
-	// 更新使用者的使用額度
+	createInfo := &models.LinkCreateInfo{
+		Type:    linkType,
+		Custom:  custom,
+		Host:    host,
+		Dest:    dest,
+		UTMInfo: utm,
+		Creator: userInfo.ID,
+		Note:    req.GetNote(),
+		Tags:    req.GetTags(),
+		Folder:  folderID(folder),
 
-	quotaUpdateInfo := &userPB.LinkQuotaUpdateRequest{
-		UserIdHex:       userInfo.IDHex,
+		Passthrough:  passthroughInfo,
+		RedirectCode: int(req.GetRedirectCode()),
+	}
+	if workspace != nil {
+		createInfo.Workspace = workspace.WorkspaceID
+	}
+	// 更新使用者的使用額度在 commit 後由 outbox 執行，redis 由 rdSyncLoop 同步
+
+	quotaInfo := &models.OutboxQuotaInfo{
+		UserIDHex:       userInfo.IDHex,
 		NormalUsageDiff: 1,
 	}
+	if workspace != nil {
+		quotaInfo.WorkspaceIDHex = workspace.WorkspaceIDHex
+	}
 	if custom != "" {
-		quotaUpdateInfo.CustomUsageDiff = 1
+		quotaInfo.CustomUsageDiff = 1
 	}
 
-	_, err = lc.SrvcConn.User.LinkQuotaUpdate(ctx, quotaUpdateInfo)
+	var ops []*models.OutboxInfo
+	_, err = models.LinkCreate(ctx, createInfo, lc.codeGenerator(createInfo.Host),
+		func(sessCtx context.Context, link *models.LinkInfo) error {
+			err := models.LinkHistoryAdd(sessCtx,
+				models.NewLinkHistory(link.Id, models.HALinkCreate, userInfo.ID, nil, link.HistoryValues()))
+			if err != nil {
+				return err
+			}
+			ops = []*models.OutboxInfo{models.NewQuotaOutbox(quotaInfo)}
+			return models.OutboxAdd(sessCtx, ops...)
+		})
 	if err != nil {
-		lc.Logger.Error("User.LinkQuotaUpdate failed", zap.Error(err))
-		err = common.GRPCErrInternal
 		return
 	}
+	lc.outboxApplyNow(ctx, ops...)
 
 	resp = &linkPB.LinkCreateResponse{
 		Msg: "success",
@@ -144,6 +250,17 @@ func (lc *LinkController) mLinkInfoToPBLinkInfo(mLink *models.LinkInfo) *linkPB.
 	} else {
 		short = mLink.Host + "/" + mLink.Short
 	}
+	var healthCheckAt *timestamppb.Timestamp
+	if !mLink.Health.CheckAt.IsZero() {
+		healthCheckAt = timestamppb.New(mLink.Health.CheckAt)
+	}
+	var folderIDHex, workspaceIDHex string
+	if !mLink.Folder.IsZero() {
+		folderIDHex = mLink.Folder.Hex()
+	}
+	if !mLink.Workspace.IsZero() {
+		workspaceIDHex = mLink.Workspace.Hex()
+	}
 	return &linkPB.LinkInfo{
 		IdHex:        mLink.Id.Hex(),
 		Type:         int32(mLink.Type),
@@ -162,14 +279,27 @@ func (lc *LinkController) mLinkInfoToPBLinkInfo(mLink *models.LinkInfo) *linkPB.
 		BrowserClicks: mLink.BrowserClicks,
 
 		CreateAt: timestamppb.New(mLink.CreateAt),
+
+		HealthStatus:    int32(mLink.Health.StatusCode),
+		HealthLatencyMs: uint32(mLink.Health.LatencyMS),
+		HealthBroken:    mLink.Health.Broken,
+		HealthCheckAt:   healthCheckAt,
+
+		FolderIdHex:    folderIDHex,
+		WorkspaceIdHex: workspaceIDHex,
+		Passthrough:    mPassthroughInfoToPBPassthroughInfo(mLink.Passthrough),
+		RedirectCode:   int32(mLink.RedirectCode),
 	}
 }
 
 func (lc *LinkController) LinkList(ctx context.Context, req *linkPB.LinkListRequest) (resp *linkPB.LinkListResponse, err error) {
 	// 請求資料檢查
 
+	if err = workspaceIDArgumentCheck(req.GetWorkspaceIdHex()); err != nil {
+		return
+	}
 	var toListUserID primitive.ObjectID
-	if !req.GetAllUser() {
+	if !req.GetAllUser() && req.GetWorkspaceIdHex() == "" {
 		toListUserID, err = primitive.ObjectIDFromHex(req.GetUserIdHex())
 		if err != nil {
 			err = status.Error(codes.InvalidArgument, "user id format is invalid")
@@ -185,6 +315,10 @@ func (lc *LinkController) LinkList(ctx context.Context, req *linkPB.LinkListRequ
 	if err = tagsArgumentCheck(req.GetTags()); err != nil {
 		return
 	}
+	filterFolder, err := folderIDArgumentParse(req.GetFolderIdHex())
+	if err != nil {
+		return
+	}
 	if req.GetPage() == 0 {
 		err = status.Error(codes.InvalidArgument, "page needs to be a value greater than 0")
 		return
@@ -204,7 +338,15 @@ func (lc *LinkController) LinkList(ctx context.Context, req *linkPB.LinkListRequ
 	if err != nil {
 		return
 	}
-	if req.GetAllUser() {
+	var listWorkspace primitive.ObjectID
+	if req.GetWorkspaceIdHex() != "" {
+		var member *common.WorkspaceMemberInfo
+		member, err = lc.workspaceMemberGet(ctx, req.GetWorkspaceIdHex(), userInfo)
+		if err != nil {
+			return
+		}
+		listWorkspace = member.WorkspaceID
+	} else if req.GetAllUser() {
 		if !userInfo.IsManager {
 			err = common.GRPCERRPermissionDenied
 			return
@@ -218,8 +360,15 @@ func (lc *LinkController) LinkList(ctx context.Context, req *linkPB.LinkListRequ
 
 	skip := int64((req.GetPage() - 1) * req.GetPageSize())
 	linkList, err := models.LinkList(ctx,
-		req.GetAllUser(), toListUserID,
-		req.GetSortBy(), req.GetReverse(), req.GetTags(),
+		&models.LinkListFilter{
+			AllUser:    req.GetAllUser(),
+			UserID:     toListUserID,
+			Tags:       req.GetTags(),
+			OnlyBroken: req.GetOnlyBroken(),
+			Folder:     filterFolder,
+			Workspace:  listWorkspace,
+		},
+		req.GetSortBy(), req.GetReverse(),
 		skip, int64(req.GetPageSize()))
 	if err != nil {
 		return
@@ -240,8 +389,11 @@ func (lc *LinkController) LinkListCount(ctx context.Context,
 	req *linkPB.LinkListCountRequest) (resp *linkPB.LinkListCountResponse, err error) {
 	// 請求資料檢查
 
+	if err = workspaceIDArgumentCheck(req.GetWorkspaceIdHex()); err != nil {
+		return
+	}
 	var toListUserID primitive.ObjectID
-	if !req.GetAllUser() {
+	if !req.GetAllUser() && req.GetWorkspaceIdHex() == "" {
 		toListUserID, err = primitive.ObjectIDFromHex(req.GetUserIdHex())
 		if err != nil {
 			err = status.Error(codes.InvalidArgument, "user id format is invalid")
@@ -251,6 +403,10 @@ func (lc *LinkController) LinkListCount(ctx context.Context,
 	if err = tagsArgumentCheck(req.GetTags()); err != nil {
 		return
 	}
+	filterFolder, err := folderIDArgumentParse(req.GetFolderIdHex())
+	if err != nil {
+		return
+	}
 
 	// 權限檢查
 
@@ -258,7 +414,15 @@ func (lc *LinkController) LinkListCount(ctx context.Context,
 	if err != nil {
 		return
 	}
-	if req.GetAllUser() {
+	var listWorkspace primitive.ObjectID
+	if req.GetWorkspaceIdHex() != "" {
+		var member *common.WorkspaceMemberInfo
+		member, err = lc.workspaceMemberGet(ctx, req.GetWorkspaceIdHex(), userInfo)
+		if err != nil {
+			return
+		}
+		listWorkspace = member.WorkspaceID
+	} else if req.GetAllUser() {
 		if !userInfo.IsManager {
 			err = common.GRPCERRPermissionDenied
 			return
@@ -270,7 +434,14 @@ func (lc *LinkController) LinkListCount(ctx context.Context,
 		}
 	}
 
-	totalNum, err := models.LinkListCount(ctx, req.GetAllUser(), toListUserID, req.GetTags())
+	totalNum, err := models.LinkListCount(ctx, &models.LinkListFilter{
+		AllUser:    req.GetAllUser(),
+		UserID:     toListUserID,
+		Tags:       req.GetTags(),
+		OnlyBroken: req.GetOnlyBroken(),
+		Folder:     filterFolder,
+		Workspace:  listWorkspace,
+	})
 	if err != nil {
 		return
 	}
@@ -299,6 +470,25 @@ func (lc *LinkController) LinkPatch(ctx context.Context, req *linkPB.LinkPatchRe
 			return
 		}
 	}
+	if req.GetPatchFolder() {
+		hasPatch = true
+		if _, err = folderIDArgumentParse(req.GetFolderIdHex()); err != nil {
+			return
+		}
+	}
+	var passthroughInfo *models.LinkPassthroughInfo
+	if req.GetPatchPassthrough() {
+		hasPatch = true
+		if passthroughInfo, err = passthroughArgumentGet(req.GetPassthrough()); err != nil {
+			return
+		}
+	}
+	if req.GetPatchRedirectCode() {
+		hasPatch = true
+		if err = redirectCodeArgumentCheck(req.GetRedirectCode()); err != nil {
+			return
+		}
+	}
 	if !hasPatch {
 		err = status.Error(codes.InvalidArgument, "no patch field")
 		return
@@ -318,17 +508,40 @@ func (lc *LinkController) LinkPatch(ctx context.Context, req *linkPB.LinkPatchRe
 	toPatchLink, exist, err := models.LinkFindByID(ctx, toPatchLinkID)
 	if err != nil {
 		return
-	} else if !exist || userInfo.ID != toPatchLink.Creator {
+	} else if !exist {
 		err = common.GRPCERRPermissionDenied
 		return
 	}
+	if err = lc.linkEditPermCheck(ctx, userInfo, toPatchLink); err != nil {
+		return
+	}
+	var folder *models.FolderInfo
+	if req.GetPatchFolder() && req.GetFolderIdHex() != "" && !toPatchLink.Workspace.IsZero() {
+		err = status.Error(codes.InvalidArgument, "folder can not be used with workspace link")
+		return
+	}
+	if req.GetPatchFolder() {
+		folder, err = folderArgumentGet(ctx, userInfo.ID, req.GetFolderIdHex())
+		if err != nil {
+			return
+		}
+	}
 
-	err = toPatchLink.Patch(ctx, &models.LinkPatchInfo{
-		PNote: req.GetPatchNote(),
-		Note:  req.GetNote(),
-		PTags: req.GetPatchTags(),
-		Tags:  req.GetTags(),
-	})
+	patchInfo := &models.LinkPatchInfo{
+		PNote:   req.GetPatchNote(),
+		Note:    req.GetNote(),
+		PTags:   req.GetPatchTags(),
+		Tags:    req.GetTags(),
+		PFolder: req.GetPatchFolder(),
+		Folder:  folderID(folder),
+
+		PPassthrough: req.GetPatchPassthrough(),
+		Passthrough:  passthroughInfo,
+
+		PRedirectCode: req.GetPatchRedirectCode(),
+		RedirectCode:  int(req.GetRedirectCode()),
+	}
+	err = toPatchLink.Patch(ctx, userInfo.ID, patchInfo)
 	if err != nil {
 		return
 	}
@@ -355,19 +568,17 @@ func (lc *LinkController) LinkDelete(ctx context.Context, req *linkPB.LinkDelete
 	toDeleteLink, exist, err := models.LinkFindByID(ctx, toDeleteLinkID)
 	if err != nil {
 		return
-	} else if !exist || userInfo.ID != toDeleteLink.Creator {
+	} else if !exist {
 		err = common.GRPCERRPermissionDenied
 		return
 	}
+	if err = lc.linkEditPermCheck(ctx, userInfo, toDeleteLink); err != nil {
+		return
+	}
 
 	if !toDeleteLink.Deleted {
-		err = toDeleteLink.Delete(ctx)
-		if err != nil {
-			return
-		}
-		err = rdModels.LinkDelete(ctx, toDeleteLink)
+		err = toDeleteLink.Delete(ctx, userInfo.ID)
 		if err != nil {
-			_ = toDeleteLink.SetNoDelete(ctx)
 			return
 		}
 	}
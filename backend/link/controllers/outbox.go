@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"URLS/link/models"
+	userPB "URLS/proto/gen/go/user/v1"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// outboxScanInterval 尋找需要執行的操作的間隔
+	outboxScanInterval = time.Second
+	// outboxLease 操作被取得後，在此時間內不會被其他 service 重複執行
+	outboxLease = 30 * time.Second
+	// outboxRetryMaxDelay 執行失敗後重試的最長間隔
+	outboxRetryMaxDelay = 5 * time.Minute
+)
+
+// errOutboxDrop 操作已無法執行，不需要重試
+var errOutboxDrop = errors.New("outbox operation can not be applied")
+
+// outboxRetryDelay 回傳第 attempts 次執行失敗後，重新執行前需要等待的時間
+func outboxRetryDelay(attempts int) time.Duration {
+	if attempts <= 0 {
+		attempts = 1
+	}
+	delay := time.Second
+	for i := 1; i < attempts && delay < outboxRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxRetryMaxDelay {
+		delay = outboxRetryMaxDelay
+	}
+	return delay
+}
+
+// outboxLoop 定期執行 outbox 中的操作，包含 commit 後立即執行失敗的操作
+func (lc *LinkController) outboxLoop() {
+	ticker := time.NewTicker(outboxScanInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		lc.outboxRound(context.Background())
+	}
+}
+
+// outboxRound 執行所有目前可以執行的操作
+func (lc *LinkController) outboxRound(ctx context.Context) {
+	for {
+		op, exist, err := models.OutboxClaim(ctx, outboxLease)
+		if err != nil || !exist {
+			return
+		}
+		lc.outboxProcess(ctx, op)
+	}
+}
+
+// outboxApplyNow 在 commit 後立即嘗試執行 ops，失敗的操作會由 outboxLoop 重試
+func (lc *LinkController) outboxApplyNow(ctx context.Context, ops ...*models.OutboxInfo) {
+	for _, op := range ops {
+		claimed, err := op.Claim(ctx, outboxLease)
+		if err != nil || !claimed {
+			continue
+		}
+		lc.outboxProcess(ctx, op)
+	}
+}
+
+// outboxProcess 執行已取得的操作，並根據結果移除或安排重試
+func (lc *LinkController) outboxProcess(ctx context.Context, op *models.OutboxInfo) {
+	err := lc.outboxApply(ctx, op)
+	if err == nil || errors.Is(err, errOutboxDrop) {
+		if err != nil {
+			lc.Logger.Warn("outbox operation dropped",
+				zap.String("id", op.Id.Hex()), zap.String("kind", string(op.Kind)), zap.Error(err))
+		}
+		_ = op.Done(ctx)
+		return
+	}
+
+	lc.Logger.Warn("outbox operation failed, retry later",
+		zap.String("id", op.Id.Hex()), zap.String("kind", string(op.Kind)),
+		zap.Int("attempts", op.Attempts), zap.Error(err))
+	_ = op.Retry(ctx, err.Error(), outboxRetryDelay(op.Attempts))
+}
+
+// outboxApply 執行操作，同一個操作重複執行的結果相同
+func (lc *LinkController) outboxApply(ctx context.Context, op *models.OutboxInfo) (err error) {
+	switch op.Kind {
+	case models.OKQuota:
+		if op.Quota == nil {
+			return errOutboxDrop
+		}
+		_, err = lc.SrvcConn.User.LinkQuotaUpdate(ctx, &userPB.LinkQuotaUpdateRequest{
+			UserIdHex:       op.Quota.UserIDHex,
+			WorkspaceIdHex:  op.Quota.WorkspaceIDHex,
+			NormalUsageDiff: op.Quota.NormalUsageDiff,
+			CustomUsageDiff: op.Quota.CustomUsageDiff,
+			OpId:            op.Id.Hex(),
+		})
+		switch status.Code(err) {
+		case codes.NotFound, codes.InvalidArgument:
+			// 使用者或工作區已被刪除，重試也不會成功
+			return fmt.Errorf("%w: %s", errOutboxDrop, err)
+		}
+		return err
+
+	default:
+		return errOutboxDrop
+	}
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"strconv"
+
+	"URLS/link/models"
+	"URLS/link/pkg/passthrough"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// passthroughAllowMaxLen 允許傳遞的參數名稱最大數量
+const passthroughAllowMaxLen = 20
+const passthroughNameMaxLen = 50
+
+// passthroughArgumentGet 檢查 query passthrough 設定，未啟用時回傳 nil
+func passthroughArgumentGet(info *linkPB.PassthroughInfo) (pInfo *models.LinkPassthroughInfo, err error) {
+	if !info.GetEnabled() {
+		return nil, nil
+	}
+
+	conflict := passthrough.ConflictIncoming
+	if info.GetConflict() != 0 {
+		var convOK bool
+		conflict, convOK = passthrough.ConflictFromInteger(info.GetConflict())
+		if !convOK {
+			err = status.Error(codes.InvalidArgument, "passthrough conflict is invalid")
+			return
+		}
+	}
+	if len(info.GetAllow()) > passthroughAllowMaxLen {
+		err = status.Error(codes.InvalidArgument, "the number of passthrough allow is greater than "+strconv.Itoa(passthroughAllowMaxLen))
+		return
+	}
+	for _, name := range info.GetAllow() {
+		if name == "" {
+			err = status.Error(codes.InvalidArgument, "passthrough allow can not contain empty name")
+			return
+		}
+		if len(name) > passthroughNameMaxLen {
+			err = status.Error(codes.InvalidArgument, "length of passthrough allow name is greater than "+strconv.Itoa(passthroughNameMaxLen))
+			return
+		}
+	}
+
+	pInfo = &models.LinkPassthroughInfo{
+		Conflict: conflict,
+		Allow:    info.GetAllow(),
+	}
+	return pInfo, nil
+}
+
+func mPassthroughInfoToPBPassthroughInfo(pInfo *models.LinkPassthroughInfo) *linkPB.PassthroughInfo {
+	if pInfo == nil {
+		return &linkPB.PassthroughInfo{}
+	}
+	return &linkPB.PassthroughInfo{
+		Enabled:  true,
+		Conflict: int32(pInfo.Conflict),
+		Allow:    pInfo.Allow,
+	}
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"URLS/link/models"
+	rdModels "URLS/redirector/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+const (
+	// rdSyncRetryInterval change stream 中斷或沒有取得 lease 時重新嘗試的間隔
+	rdSyncRetryInterval = 5 * time.Second
+	// rdSyncLease 監聽 change stream 的 lease，持有者每 rdSyncLease/3 延長一次
+	rdSyncLease = 30 * time.Second
+)
+
+// rdSyncLoop 依照 link 的 change stream 將新增與修改的短網址寫入 redirector 的 redis
+//
+// 每個 link service 都會執行，但只有取得 lease 的 service 會監聽 change stream
+func (lc *LinkController) rdSyncLoop() {
+	ctx := context.Background()
+	holder := primitive.NewObjectID().Hex()
+	for {
+		acquired, err := models.LinkWatchLeaseAcquire(ctx, holder, rdSyncLease)
+		if err == nil && acquired {
+			lc.rdSyncLead(ctx, holder)
+		}
+		time.Sleep(rdSyncRetryInterval)
+	}
+}
+
+// rdSyncLead 持有 lease 時監聽 change stream，無法延長 lease 時停止
+func (lc *LinkController) rdSyncLead(ctx context.Context, holder string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(rdSyncLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			acquired, err := models.LinkWatchLeaseAcquire(ctx, holder, rdSyncLease)
+			if err != nil || !acquired {
+				lc.Logger.Warn("link watch lease lost, stop syncing redirector")
+				return
+			}
+		}
+	}()
+
+	for ctx.Err() == nil {
+		err := lc.rdSyncWatch(ctx)
+		if errors.Is(err, models.ErrLinkWatchTokenLost) {
+			lc.Logger.Error("link change stream can not resume, reconcile the redirector records before resuming")
+			if err = lc.rdSyncRestart(ctx); err != nil {
+				lc.Logger.Error("restart link change stream failed", zap.Error(err))
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(rdSyncRetryInterval):
+		}
+	}
+}
+
+// rdSyncRestart 從目前的時間重新開始監聽，並標記在繼續處理前需要先重建 redirector 的資料
+func (lc *LinkController) rdSyncRestart(ctx context.Context) (err error) {
+	if err = models.LinkWatchTokenReset(ctx); err != nil {
+		return
+	}
+	stream, err := models.LinkWatch(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer stream.Close(ctx)
+
+	return models.LinkWatchTokenSave(ctx, stream.Token(), true)
+}
+
+// rdSyncReconcile 依照 mongo 重建 redirector 的資料，完成前不會處理 change stream
+func (lc *LinkController) rdSyncReconcile(ctx context.Context, token bson.Raw) (err error) {
+	lc.Logger.Info("reconciling redirector records")
+	cp := new(rdModels.RebuildCheckpoint)
+	if err = rdModels.Rebuild(ctx, cp, &rdModels.RebuildOptions{}); err != nil {
+		lc.Logger.Error("rdModels.Rebuild failed", zap.Error(err), zap.Any("checkpoint", cp))
+		return
+	}
+	lc.Logger.Info("redirector records reconciled", zap.Any("report", cp.Report))
+
+	return models.LinkWatchTokenSave(ctx, token, false)
+}
+
+// rdSyncWatch 從上次處理到的位置開始監聽，發生錯誤時回傳
+//
+// 重建期間的變更仍然在 token 之後，重建完成後會再被處理一次，寫入的內容只與 link 目前的狀態有關
+func (lc *LinkController) rdSyncWatch(ctx context.Context) (err error) {
+	token, reconcile, err := models.LinkWatchTokenGet(ctx)
+	if err != nil {
+		return
+	}
+	if reconcile {
+		if err = lc.rdSyncReconcile(ctx, token); err != nil {
+			return
+		}
+	}
+	stream, err := models.LinkWatch(ctx, token)
+	if err != nil {
+		return
+	}
+	defer stream.Close(ctx)
+
+	for {
+		var link *models.LinkInfo
+		link, err = stream.Next(ctx)
+		if err != nil {
+			return
+		}
+		if err = rdModels.LinkSync(ctx, link); err != nil {
+			lc.Logger.Warn("sync link to redirector failed",
+				zap.String("short", link.Short), zap.String("host", link.Host), zap.Error(err))
+			return
+		}
+		if err = models.LinkWatchTokenSave(ctx, stream.Token(), false); err != nil {
+			return
+		}
+	}
+}
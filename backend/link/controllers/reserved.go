@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"URLS/internal/common"
+	"URLS/link/models"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const reservedWordAddMaxLen = 100
+
+// builtinReservedWords 服務路由與常見的網站檔案路徑，不能被使用為客製化短網址
+func builtinReservedWords() []string {
+	return []string{
+		strings.Trim(common.GWWebPrefix, "/"),
+		strings.Trim(common.GWAPIPrefix, "/"),
+		strings.TrimPrefix(common.RDFaviconPath, "/"),
+		"robots.txt",
+	}
+}
+
+// customReservedCheck 檢查客製化短網址是否為保留字
+func customReservedCheck(ctx context.Context, custom string) (err error) {
+	reserved, err := models.IsReservedWord(ctx, custom)
+	if err != nil {
+		return
+	} else if reserved {
+		err = status.Error(codes.InvalidArgument, "custom link is a reserved word")
+		return
+	}
+
+	return nil
+}
+
+func (lc *LinkController) ReservedWordList(ctx context.Context,
+	req *linkPB.ReservedWordListRequest) (resp *linkPB.ReservedWordListResponse, err error) {
+	_, err = lc.managerRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	list, err := models.ReservedWordList(ctx)
+	if err != nil {
+		return
+	}
+
+	pbList := make([]*linkPB.ReservedWordInfo, 0, len(list))
+	for _, info := range list {
+		pbInfo := &linkPB.ReservedWordInfo{
+			Word:     info.Word,
+			Builtin:  info.Builtin,
+			CreateAt: timestamppb.New(info.CreateAt),
+		}
+		if info.Creator != primitive.NilObjectID {
+			pbInfo.CreatorIdHex = info.Creator.Hex()
+		}
+		pbList = append(pbList, pbInfo)
+	}
+
+	resp = &linkPB.ReservedWordListResponse{
+		ReservedWordList: pbList,
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) ReservedWordAdd(ctx context.Context,
+	req *linkPB.ReservedWordAddRequest) (resp *linkPB.ReservedWordAddResponse, err error) {
+	// 請求資料檢查
+
+	if len(req.GetWords()) == 0 {
+		err = status.Error(codes.InvalidArgument, "words can not be empty")
+		return
+	}
+	if len(req.GetWords()) > reservedWordAddMaxLen {
+		err = status.Error(codes.InvalidArgument, "too many words in one request")
+		return
+	}
+	for _, word := range req.GetWords() {
+		if models.ReservedWordNormalize(word) == "" {
+			err = status.Error(codes.InvalidArgument, "word can not be empty string")
+			return
+		}
+	}
+
+	userInfo, err := lc.managerRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	err = models.ReservedWordAdd(ctx, req.GetWords(), userInfo.ID)
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.ReservedWordAddResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) ReservedWordRemove(ctx context.Context,
+	req *linkPB.ReservedWordRemoveRequest) (resp *linkPB.ReservedWordRemoveResponse, err error) {
+	if models.ReservedWordNormalize(req.GetWord()) == "" {
+		err = status.Error(codes.InvalidArgument, "word can not be empty string")
+		return
+	}
+
+	_, err = lc.managerRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	exist, err := models.ReservedWordRemove(ctx, req.GetWord())
+	if err != nil {
+		return
+	} else if !exist {
+		err = status.Error(codes.NotFound, "word was not found or can not be removed")
+		return
+	}
+
+	resp = &linkPB.ReservedWordRemoveResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+
+	"URLS/link/models"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tagMergeMaxLen 一次最多合併的 tag 數量
+const tagMergeMaxLen = 50
+
+func (lc *LinkController) TagRename(ctx context.Context, req *linkPB.TagRenameRequest) (resp *linkPB.TagRenameResponse, err error) {
+	// 請求資料檢查
+
+	if err = tagsArgumentCheck([]string{req.GetTag(), req.GetNewTag()}); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	modifiedNum, err := models.TagsReplace(ctx, userInfo.ID, []string{req.GetTag()}, req.GetNewTag())
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.TagRenameResponse{
+		AffectedNum: uint64(modifiedNum),
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) TagMerge(ctx context.Context, req *linkPB.TagMergeRequest) (resp *linkPB.TagMergeResponse, err error) {
+	// 請求資料檢查
+
+	if len(req.GetTags()) == 0 {
+		err = status.Error(codes.InvalidArgument, "tags can not be empty")
+		return
+	}
+	if len(req.GetTags()) > tagMergeMaxLen {
+		err = status.Error(codes.InvalidArgument, "length of tags is greater than "+strconv.Itoa(tagMergeMaxLen))
+		return
+	}
+	for _, tag := range append([]string{req.GetTarget()}, req.GetTags()...) {
+		if err = tagsArgumentCheck([]string{tag}); err != nil {
+			return
+		}
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	modifiedNum, err := models.TagsReplace(ctx, userInfo.ID, req.GetTags(), req.GetTarget())
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.TagMergeResponse{
+		AffectedNum: uint64(modifiedNum),
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) TagDelete(ctx context.Context, req *linkPB.TagDeleteRequest) (resp *linkPB.TagDeleteResponse, err error) {
+	if err = tagsArgumentCheck([]string{req.GetTag()}); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+
+	modifiedNum, err := models.TagRemove(ctx, userInfo.ID, req.GetTag())
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.TagDeleteResponse{
+		AffectedNum: uint64(modifiedNum),
+	}
+	return resp, nil
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+
+	"URLS/internal/common"
+	"URLS/link/models"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// transferFilterArgumentGet 檢查要轉移的 link 條件，link id、tags、all 只能指定其中一種
+func transferFilterArgumentGet(req *linkPB.LinkTransferRequest) (filter *models.LinkTransferFilter, err error) {
+	selectNum := 0
+	if req.GetLinkIdHex() != "" {
+		selectNum++
+	}
+	if len(req.GetTags()) > 0 {
+		selectNum++
+	}
+	if req.GetAll() {
+		selectNum++
+	}
+	if selectNum != 1 {
+		err = status.Error(codes.InvalidArgument, "exactly one of link id, tags and all must be specified")
+		return
+	}
+
+	filter = new(models.LinkTransferFilter)
+	if req.GetLinkIdHex() != "" {
+		filter.LinkID, err = primitive.ObjectIDFromHex(req.GetLinkIdHex())
+		if err != nil {
+			err = status.Error(codes.InvalidArgument, "link id format is invalid")
+			return nil, err
+		}
+	}
+	if len(req.GetTags()) > 0 {
+		if err = tagsArgumentCheck(req.GetTags()); err != nil {
+			return nil, err
+		}
+		filter.Tags = req.GetTags()
+	}
+
+	return filter, nil
+}
+
+// usageDiffs 計算轉移 num 個短網址時，原使用者要扣除的使用量與新使用者要增加的使用量
+//
+// 使用量只計算本期建立的短網址，因此扣除的數量不會超過原使用者目前的使用量
+func usageDiffs(usage uint64, num int64) (fromDiff, toDiff int64) {
+	fromDiff = num
+	if uint64(num) > usage {
+		fromDiff = int64(usage)
+	}
+	return -fromDiff, fromDiff
+}
+
+func (lc *LinkController) LinkTransfer(ctx context.Context,
+	req *linkPB.LinkTransferRequest) (resp *linkPB.LinkTransferResponse, err error) {
+	// 請求資料檢查
+
+	filter, err := transferFilterArgumentGet(req)
+	if err != nil {
+		return
+	}
+	if _, err = primitive.ObjectIDFromHex(req.GetFromUserIdHex()); err != nil {
+		err = status.Error(codes.InvalidArgument, "from user id format is invalid")
+		return
+	}
+	if _, err = primitive.ObjectIDFromHex(req.GetToUserIdHex()); err != nil {
+		err = status.Error(codes.InvalidArgument, "to user id format is invalid")
+		return
+	}
+	if req.GetFromUserIdHex() == req.GetToUserIdHex() {
+		err = status.Error(codes.InvalidArgument, "can not transfer links to the same user")
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	if !userInfo.IsManager && userInfo.IDHex != req.GetFromUserIdHex() {
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+
+	fromUser, err := lc.UserGetByID(ctx, req.GetFromUserIdHex())
+	if err != nil {
+		return
+	}
+	toUser, err := lc.UserGetByID(ctx, req.GetToUserIdHex())
+	if err != nil {
+		return
+	}
+	filter.From = fromUser.ID
+
+	// 轉移 link、紀錄操作與更新兩個使用者的使用額度在同一個 transaction 中，
+	// 使用額度在 commit 後由 outbox 執行，接收者的額度不足時不會轉移
+
+	var ops []*models.OutboxInfo
+	ids, _, err := models.LinkTransfer(ctx, userInfo.ID, filter, toUser.ID,
+		func(sessCtx context.Context, ids []primitive.ObjectID, customNum int64) error {
+			fromNormalDiff, toNormalDiff := usageDiffs(fromUser.NormalLinkUsage, int64(len(ids)))
+			fromCustomDiff, toCustomDiff := usageDiffs(fromUser.CustomLinkUsage, customNum)
+			if toUser.NormalLinkUsage+uint64(toNormalDiff) > toUser.NormalLinkQuota ||
+				toUser.CustomLinkUsage+uint64(toCustomDiff) > toUser.CustomLinkQuota {
+				return status.Error(codes.ResourceExhausted, "quota of to user is not enough")
+			}
+
+			err := models.AuditAdd(sessCtx, models.AALinkTransfer, userInfo.ID, bson.M{
+				"from":      fromUser.ID,
+				"to":        toUser.ID,
+				"linkid":    filter.LinkID,
+				"tags":      filter.Tags,
+				"all":       req.GetAll(),
+				"links":     ids,
+				"customnum": customNum,
+			})
+			if err != nil {
+				return err
+			}
+
+			ops = nil
+			if toNormalDiff != 0 || toCustomDiff != 0 {
+				ops = []*models.OutboxInfo{
+					models.NewQuotaOutbox(&models.OutboxQuotaInfo{
+						UserIDHex:       fromUser.IDHex,
+						NormalUsageDiff: fromNormalDiff,
+						CustomUsageDiff: fromCustomDiff,
+					}),
+					models.NewQuotaOutbox(&models.OutboxQuotaInfo{
+						UserIDHex:       toUser.IDHex,
+						NormalUsageDiff: toNormalDiff,
+						CustomUsageDiff: toCustomDiff,
+					}),
+				}
+			}
+			return models.OutboxAdd(sessCtx, ops...)
+		})
+	if err != nil {
+		return
+	}
+	if len(ids) == 0 {
+		if !filter.LinkID.IsZero() {
+			err = status.Error(codes.NotFound, "link was not found")
+			return
+		}
+		resp = &linkPB.LinkTransferResponse{}
+		return resp, nil
+	}
+	lc.outboxApplyNow(ctx, ops...)
+
+	resp = &linkPB.LinkTransferResponse{
+		TransferredNum: uint64(len(ids)),
+	}
+	return resp, nil
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"URLS/internal/common"
+	"URLS/link/models"
+	linkPB "URLS/proto/gen/go/link/v1"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const utmTemplateNameMaxLen = 30
+
+// utmTemplateMaxNum 每個使用者或工作區最多可以建立的 UTM 範本數量
+const utmTemplateMaxNum = 100
+
+// utmArgumentCheck 檢查 UTM 參數是否有效
+func utmArgumentCheck(utm *linkPB.UTMInfo) (err error) {
+	if len(utm.GetSource()) > utmMaxLen ||
+		len(utm.GetMedium()) > utmMaxLen ||
+		len(utm.GetCampaign()) > utmMaxLen ||
+		len(utm.GetTerm()) > utmMaxLen ||
+		len(utm.GetContent()) > utmMaxLen {
+		err = status.Error(codes.InvalidArgument, "length of utm is greater than "+strconv.Itoa(utmMaxLen))
+		return
+	}
+
+	return nil
+}
+
+// utmTemplateNameArgumentCheck 檢查 UTM 範本名稱是否有效
+func utmTemplateNameArgumentCheck(name string) (err error) {
+	if strings.TrimSpace(name) == "" {
+		err = status.Error(codes.InvalidArgument, "utm template name can not be empty")
+		return
+	}
+	if len(name) > utmTemplateNameMaxLen {
+		err = status.Error(codes.InvalidArgument, "length of utm template name is greater than "+strconv.Itoa(utmTemplateNameMaxLen))
+		return
+	}
+
+	return nil
+}
+
+// utmTemplateIDArgumentParse 解析 UTM 範本 id
+func utmTemplateIDArgumentParse(idHex string) (id primitive.ObjectID, err error) {
+	id, err = primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "utm template id format is invalid")
+		return
+	}
+
+	return id, nil
+}
+
+// utmTemplateOwnerGet 取得要存取的範本擁有者，workspaceIDHex 為空時為 user 本身
+//
+// 存取工作區的範本需要是工作區的成員，edit 為 true 時需要工作區的編輯權限
+func (lc *LinkController) utmTemplateOwnerGet(ctx context.Context,
+	userInfo *common.UserInfo, workspaceIDHex string, edit bool) (owner primitive.ObjectID, err error) {
+	if workspaceIDHex == "" {
+		return userInfo.ID, nil
+	}
+
+	member, err := lc.workspaceMemberGet(ctx, workspaceIDHex, userInfo)
+	if err != nil {
+		return
+	}
+	if edit && !member.CanEdit() {
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+
+	return member.WorkspaceID, nil
+}
+
+// utmTemplateArgumentGet 取得 user 可以存取的 UTM 範本
+func (lc *LinkController) utmTemplateArgumentGet(ctx context.Context,
+	userInfo *common.UserInfo, idHex string, edit bool) (template *models.UTMTemplateInfo, err error) {
+	id, err := utmTemplateIDArgumentParse(idHex)
+	if err != nil {
+		return
+	}
+
+	template, exist, err := models.UTMTemplateFindByID(ctx, id)
+	if err != nil {
+		return
+	} else if !exist {
+		err = status.Error(codes.NotFound, "utm template was not found")
+		return nil, err
+	}
+
+	var workspaceIDHex string
+	if template.Workspace {
+		workspaceIDHex = template.Owner.Hex()
+	}
+	owner, err := lc.utmTemplateOwnerGet(ctx, userInfo, workspaceIDHex, edit)
+	if err != nil {
+		return nil, err
+	}
+	if owner != template.Owner {
+		err = status.Error(codes.NotFound, "utm template was not found")
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func mUTMTemplateInfoToPBUTMTemplateInfo(template *models.UTMTemplateInfo) *linkPB.UTMTemplateInfo {
+	pbInfo := &linkPB.UTMTemplateInfo{
+		IdHex:    template.Id.Hex(),
+		Name:     template.Name,
+		UtmInfo:  template.UTMInfo.ToPB(),
+		CreateAt: timestamppb.New(template.CreateAt),
+	}
+	if template.Workspace {
+		pbInfo.WorkspaceIdHex = template.Owner.Hex()
+	}
+	return pbInfo
+}
+
+func (lc *LinkController) UTMTemplateCreate(ctx context.Context,
+	req *linkPB.UTMTemplateCreateRequest) (resp *linkPB.UTMTemplateCreateResponse, err error) {
+	// 請求資料檢查
+
+	if err = utmTemplateNameArgumentCheck(req.GetName()); err != nil {
+		return
+	}
+	if err = utmArgumentCheck(req.GetUtmInfo()); err != nil {
+		return
+	}
+	if err = workspaceIDArgumentCheck(req.GetWorkspaceIdHex()); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	owner, err := lc.utmTemplateOwnerGet(ctx, userInfo, req.GetWorkspaceIdHex(), true)
+	if err != nil {
+		return
+	}
+
+	num, err := models.UTMTemplateCountByOwner(ctx, owner)
+	if err != nil {
+		return
+	}
+	if num >= utmTemplateMaxNum {
+		err = status.Error(codes.ResourceExhausted, "the number of utm templates has reached the limit")
+		return
+	}
+
+	template, err := models.UTMTemplateCreate(ctx, owner, req.GetWorkspaceIdHex() != "",
+		req.GetName(), models.UTMInfoFromPB(req.GetUtmInfo()))
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.UTMTemplateCreateResponse{
+		UtmTemplateInfo: mUTMTemplateInfoToPBUTMTemplateInfo(template),
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) UTMTemplateList(ctx context.Context,
+	req *linkPB.UTMTemplateListRequest) (resp *linkPB.UTMTemplateListResponse, err error) {
+	if err = workspaceIDArgumentCheck(req.GetWorkspaceIdHex()); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	owner, err := lc.utmTemplateOwnerGet(ctx, userInfo, req.GetWorkspaceIdHex(), false)
+	if err != nil {
+		return
+	}
+
+	list, err := models.UTMTemplateListByOwner(ctx, owner)
+	if err != nil {
+		return
+	}
+
+	pbList := make([]*linkPB.UTMTemplateInfo, 0, len(list))
+	for _, template := range list {
+		pbList = append(pbList, mUTMTemplateInfoToPBUTMTemplateInfo(template))
+	}
+
+	resp = &linkPB.UTMTemplateListResponse{
+		UtmTemplateInfoList: pbList,
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) UTMTemplatePatch(ctx context.Context,
+	req *linkPB.UTMTemplatePatchRequest) (resp *linkPB.UTMTemplatePatchResponse, err error) {
+	// 請求資料檢查
+
+	if !req.GetPatchName() && !req.GetPatchUtmInfo() {
+		err = status.Error(codes.InvalidArgument, "no patch field")
+		return
+	}
+	if req.GetPatchName() {
+		if err = utmTemplateNameArgumentCheck(req.GetName()); err != nil {
+			return
+		}
+	}
+	if req.GetPatchUtmInfo() {
+		if err = utmArgumentCheck(req.GetUtmInfo()); err != nil {
+			return
+		}
+	}
+	if _, err = utmTemplateIDArgumentParse(req.GetUtmTemplateIdHex()); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	template, err := lc.utmTemplateArgumentGet(ctx, userInfo, req.GetUtmTemplateIdHex(), true)
+	if err != nil {
+		return
+	}
+
+	err = template.Patch(ctx, &models.UTMTemplatePatchInfo{
+		PName:    req.GetPatchName(),
+		Name:     req.GetName(),
+		PUTMInfo: req.GetPatchUtmInfo(),
+		UTMInfo:  models.UTMInfoFromPB(req.GetUtmInfo()),
+	})
+	if err != nil {
+		return
+	}
+
+	resp = &linkPB.UTMTemplatePatchResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (lc *LinkController) UTMTemplateDelete(ctx context.Context,
+	req *linkPB.UTMTemplateDeleteRequest) (resp *linkPB.UTMTemplateDeleteResponse, err error) {
+	if _, err = utmTemplateIDArgumentParse(req.GetUtmTemplateIdHex()); err != nil {
+		return
+	}
+
+	userInfo, err := lc.UserRequestGet(ctx)
+	if err != nil {
+		return
+	}
+	template, err := lc.utmTemplateArgumentGet(ctx, userInfo, req.GetUtmTemplateIdHex(), true)
+	if err != nil {
+		return
+	}
+
+	if err = template.Delete(ctx); err != nil {
+		return
+	}
+
+	resp = &linkPB.UTMTemplateDeleteResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+
+	"URLS/internal/common"
+	"URLS/link/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// workspaceIDArgumentCheck 檢查 workspace id 的格式，空字串為個人的短網址
+func workspaceIDArgumentCheck(idHex string) (err error) {
+	if idHex == "" {
+		return nil
+	}
+	if _, err = primitive.ObjectIDFromHex(idHex); err != nil {
+		err = status.Error(codes.InvalidArgument, "workspace id format is invalid")
+		return
+	}
+
+	return nil
+}
+
+// workspaceMemberGet 取得 user 在工作區中的權限，不是成員時回傳 PermissionDenied
+func (lc *LinkController) workspaceMemberGet(ctx context.Context,
+	workspaceIDHex string, userInfo *common.UserInfo) (member *common.WorkspaceMemberInfo, err error) {
+	member, err = lc.WorkspaceMemberGet(ctx, workspaceIDHex, userInfo.IDHex)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			err = common.GRPCERRPermissionDenied
+		}
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// linkViewPermCheck 檢查 user 是否可以查看 link 的詳細資料
+//
+// 管理員可以查看所有 link，個人的 link 只有建立者可以查看，工作區的 link 需要是工作區的成員
+func (lc *LinkController) linkViewPermCheck(ctx context.Context,
+	userInfo *common.UserInfo, link *models.LinkInfo) (err error) {
+	if userInfo.IsManager {
+		return nil
+	}
+	if link.Workspace.IsZero() {
+		if userInfo.ID != link.Creator {
+			return common.GRPCERRPermissionDenied
+		}
+		return nil
+	}
+
+	_, err = lc.workspaceMemberGet(ctx, link.Workspace.Hex(), userInfo)
+	return err
+}
+
+// linkEditPermCheck 檢查 user 是否可以編輯、刪除 link
+//
+// 個人的 link 只有建立者可以編輯，工作區的 link 需要工作區的編輯權限
+func (lc *LinkController) linkEditPermCheck(ctx context.Context,
+	userInfo *common.UserInfo, link *models.LinkInfo) (err error) {
+	if link.Workspace.IsZero() {
+		if userInfo.ID != link.Creator {
+			return common.GRPCERRPermissionDenied
+		}
+		return nil
+	}
+
+	member, err := lc.workspaceMemberGet(ctx, link.Workspace.Hex(), userInfo)
+	if err != nil {
+		return
+	}
+	if !member.CanEdit() {
+		return common.GRPCERRPermissionDenied
+	}
+
+	return nil
+}
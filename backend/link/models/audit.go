@@ -0,0 +1,58 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"context"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+const auditCollName string = "audits" + collSuffix
+
+var auditColl *qmgo.Collection
+
+func initAuditCollIndex(ctx context.Context) (err error) {
+	err = auditColl.CreateIndexes(ctx, []options.IndexModel{
+		{Key: []string{"action", "-createAt"}},
+		{Key: []string{"operator"}},
+	})
+
+	return
+}
+
+// AuditAction 被記錄的操作
+type AuditAction string
+
+const (
+	AALinkTransfer AuditAction = "link.transfer" // 轉移短網址的擁有者
+)
+
+// AuditInfo 管理操作的紀錄
+type AuditInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Action   AuditAction        `bson:"action"`
+	Operator primitive.ObjectID `bson:"operator"` // 執行操作的使用者
+	Data     bson.M             `bson:"data"`     // 操作的詳細資料
+}
+
+// AuditAdd 新增操作紀錄
+func AuditAdd(ctx context.Context, action AuditAction, operator primitive.ObjectID, data bson.M) (err error) {
+	_, err = auditColl.InsertOne(ctx, &AuditInfo{
+		Action:   action,
+		Operator: operator,
+		Data:     data,
+	})
+	if err != nil {
+		logger.Error("insert audit failed", zap.String("action", string(action)), zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"context"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	officialOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const blockDomainCollName string = "blockdomains" + collSuffix
+
+var blockDomainColl *qmgo.Collection
+
+func initBlockDomainCollIndex(ctx context.Context) (err error) {
+	uniqueOpts := officialOpts.Index()
+	uniqueOpts.SetUnique(true)
+
+	err = blockDomainColl.CreateOneIndex(ctx,
+		options.IndexModel{Key: []string{"domain"}, IndexOptions: uniqueOpts})
+
+	return
+}
+
+// BlockDomainInfo 執行期間由管理員加入的封鎖網域
+type BlockDomainInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Domain  string             `bson:"domain"`  // 網域，"." 開頭代表包含所有子網域
+	Creator primitive.ObjectID `bson:"creator"` // 加入的管理員
+}
+
+// BlockDomainAdd 新增封鎖網域，已存在的網域會被略過
+func BlockDomainAdd(ctx context.Context, domains []string, creator primitive.ObjectID) (err error) {
+	for _, domain := range domains {
+		_, err = blockDomainColl.InsertOne(ctx, &BlockDomainInfo{
+			Domain:  domain,
+			Creator: creator,
+		})
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				err = nil
+				continue
+			}
+			logger.Error("insert block domain failed", zap.Error(err))
+			err = common.GRPCErrInternal
+			return
+		}
+	}
+
+	return nil
+}
+
+// BlockDomainRemove 移除封鎖網域
+func BlockDomainRemove(ctx context.Context, domain string) (exist bool, err error) {
+	err = blockDomainColl.Remove(ctx, bson.M{"domain": domain})
+	if err != nil {
+		if qmgo.IsErrNoDocuments(err) {
+			return false, nil
+		}
+		logger.Error("remove block domain failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return true, nil
+}
+
+// BlockDomainList 回傳所有執行期間加入的封鎖網域
+func BlockDomainList(ctx context.Context) (list []*BlockDomainInfo, err error) {
+	err = blockDomainColl.Find(ctx, bson.M{}).Sort("domain").All(&list)
+	if err != nil {
+		logger.Error("list block domain failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
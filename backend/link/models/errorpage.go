@@ -0,0 +1,209 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"URLS/internal/utils/bsonext"
+	"context"
+	"time"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	officialOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"golang.org/x/exp/constraints"
+)
+
+const errorPageCollName string = "errorpages" + collSuffix
+
+var errorPageColl *qmgo.Collection
+
+func initErrorPageCollIndex(ctx context.Context) (err error) {
+	uniqueOpts := officialOpts.Index()
+	uniqueOpts.SetUnique(true)
+
+	err = errorPageColl.CreateOneIndex(ctx,
+		options.IndexModel{Key: []string{"scope", "host", "owner", "state"}, IndexOptions: uniqueOpts})
+
+	return
+}
+
+// ErrorPageScope 錯誤頁面的套用範圍
+type ErrorPageScope int32
+
+const (
+	_           ErrorPageScope = iota
+	EPScopeUser                // 使用者建立的 link
+	EPScopeHost                // 短網址網域
+)
+
+func ErrorPageScopeFromInteger[T constraints.Integer](i T) (ErrorPageScope, bool) {
+	conv := ErrorPageScope(i)
+	switch conv {
+	case EPScopeUser, EPScopeHost:
+		return conv, true
+	default:
+		return 0, false
+	}
+}
+
+// LinkState 導向失敗時短網址的狀態
+type LinkState int32
+
+const (
+	_          LinkState = iota
+	LSNotFound           // 短網址不存在
+	LSDeleted            // 短網址已被刪除
+)
+
+func LinkStateFromInteger[T constraints.Integer](i T) (LinkState, bool) {
+	conv := LinkState(i)
+	switch conv {
+	case LSNotFound, LSDeleted:
+		return conv, true
+	default:
+		return 0, false
+	}
+}
+
+// ErrorPageInfo 短網址無法導向時顯示的頁面，FallbackURL 與 Template 只會有一個不為空，
+// EPScopeUser 只能設定 FallbackURL
+//
+// 同一個範圍與狀態只能有一個頁面
+type ErrorPageInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Scope ErrorPageScope     `bson:"scope"`
+	Host  string             `bson:"host"`            // EPScopeHost 的網域，預設的網域為空
+	Owner primitive.ObjectID `bson:"owner,omitempty"` // EPScopeUser 的使用者
+	State LinkState          `bson:"state"`
+
+	FallbackURL string `bson:"fallbackurl,omitempty"` // 導向到此網址
+	Template    string `bson:"template,omitempty"`    // 直接回應的 HTML
+}
+
+// ErrorPageSet 設定範圍與狀態的錯誤頁面，已存在時會被覆蓋
+func ErrorPageSet(ctx context.Context, page *ErrorPageInfo) (err error) {
+	filter := bson.M{"scope": page.Scope, "host": page.Host, "owner": page.Owner, "state": page.State}
+	if page.Owner.IsZero() {
+		filter["owner"] = bson.M{"$exists": false}
+	}
+
+	nowTime := time.Now()
+	set := bson.M{"updateAt": nowTime}
+	unset := bson.M{}
+	for name, value := range map[string]string{"fallbackurl": page.FallbackURL, "template": page.Template} {
+		if value != "" {
+			set[name] = value
+		} else {
+			unset[name] = ""
+		}
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"createAt": nowTime},
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// 同時設定相同的頁面時，其中一個 upsert 可能因為 unique index 失敗，此時頁面已存在，重試會更新該頁面
+	for retry := 0; ; retry++ {
+		err = errorPageColl.Find(ctx, filter).Apply(qmgo.Change{
+			Update:    update,
+			Upsert:    true,
+			ReturnNew: true,
+		}, page)
+		if err == nil || retry > 0 || !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err != nil {
+		logger.Error("set error page failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
+
+// ErrorPageFind 尋找範圍與狀態的錯誤頁面，scope 為 EPScopeHost 時 owner 需要為空，EPScopeUser 時 host 需要為空
+func ErrorPageFind(ctx context.Context, scope ErrorPageScope, host string, owner primitive.ObjectID, state LinkState) (
+	page *ErrorPageInfo, exist bool, err error) {
+	filter := bson.M{"scope": scope, "host": host, "state": state}
+	if owner.IsZero() {
+		filter["owner"] = bson.M{"$exists": false}
+	} else {
+		filter["owner"] = owner
+	}
+
+	page = new(ErrorPageInfo)
+	err = errorPageColl.Find(ctx, filter).One(page)
+	if err != nil {
+		page = nil
+		if qmgo.IsErrNoDocuments(err) {
+			return nil, false, nil
+		}
+		logger.Error("find error page failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return page, true, nil
+}
+
+// ErrorPageFindByID 根據 id 尋找錯誤頁面
+func ErrorPageFindByID(ctx context.Context, id primitive.ObjectID) (page *ErrorPageInfo, exist bool, err error) {
+	page = new(ErrorPageInfo)
+	err = errorPageColl.Find(ctx, bsonext.ID(id)).One(page)
+	if err != nil {
+		page = nil
+		if qmgo.IsErrNoDocuments(err) {
+			return nil, false, nil
+		}
+		logger.Error("find error page by id failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return page, true, nil
+}
+
+// ErrorPageListByOwner 回傳使用者的所有錯誤頁面
+func ErrorPageListByOwner(ctx context.Context, owner primitive.ObjectID) (list []*ErrorPageInfo, err error) {
+	err = errorPageColl.Find(ctx, bson.M{"scope": EPScopeUser, "owner": owner}).Sort("state").All(&list)
+	if err != nil {
+		logger.Error("list error page by owner failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// ErrorPageListByHost 回傳所有短網址網域的錯誤頁面
+func ErrorPageListByHost(ctx context.Context) (list []*ErrorPageInfo, err error) {
+	err = errorPageColl.Find(ctx, bson.M{"scope": EPScopeHost}).Sort("host", "state").All(&list)
+	if err != nil {
+		logger.Error("list error page by host failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// Delete 刪除錯誤頁面
+func (p *ErrorPageInfo) Delete(ctx context.Context) (err error) {
+	err = errorPageColl.Remove(ctx, bsonext.ID(p.Id))
+	if err != nil && !qmgo.IsErrNoDocuments(err) {
+		logger.Error("delete error page failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"URLS/internal/utils/bsonext"
+	"context"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	officialOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const folderCollName string = "folders" + collSuffix
+
+var folderColl *qmgo.Collection
+
+func initFolderCollIndex(ctx context.Context) (err error) {
+	uniqueOpts := officialOpts.Index()
+	uniqueOpts.SetUnique(true)
+
+	err = folderColl.CreateOneIndex(ctx,
+		options.IndexModel{Key: []string{"owner", "parent", "name"}, IndexOptions: uniqueOpts})
+
+	return
+}
+
+// FolderInfo 用來分類 link 的資料夾，同一層中的名稱不能重複
+type FolderInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Owner  primitive.ObjectID `bson:"owner"`            // 擁有者
+	Name   string             `bson:"name"`             // 名稱
+	Parent primitive.ObjectID `bson:"parent,omitempty"` // 上層資料夾，最上層時為空
+}
+
+// folderWriteErrConvert 將寫入時的錯誤轉換為 gRPC 錯誤
+func folderWriteErrConvert(err error, logMsg string) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return status.Error(codes.AlreadyExists, "folder with the same name already exists")
+	}
+	logger.Error(logMsg, zap.Error(err))
+	return common.GRPCErrInternal
+}
+
+// FolderCreate 建立資料夾，parent 為空時建立在最上層
+func FolderCreate(ctx context.Context, owner primitive.ObjectID, name string, parent primitive.ObjectID) (
+	*FolderInfo, error) {
+	folder := &FolderInfo{
+		Owner:  owner,
+		Name:   name,
+		Parent: parent,
+	}
+	_, err := folderColl.InsertOne(ctx, folder)
+	if err != nil {
+		return nil, folderWriteErrConvert(err, "insert folder failed")
+	}
+
+	return folder, nil
+}
+
+// FolderFindByID 根據 id 尋找資料夾
+func FolderFindByID(ctx context.Context, id primitive.ObjectID) (folder *FolderInfo, exist bool, err error) {
+	folder = new(FolderInfo)
+	err = folderColl.Find(ctx, bsonext.ID(id)).One(folder)
+	if err != nil {
+		folder = nil
+		if qmgo.IsErrNoDocuments(err) {
+			return nil, false, nil
+		}
+		logger.Error("find folder by id failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return folder, true, nil
+}
+
+// FolderListByOwner 回傳使用者的所有資料夾
+func FolderListByOwner(ctx context.Context, owner primitive.ObjectID) (list []*FolderInfo, err error) {
+	err = folderColl.Find(ctx, bson.M{"owner": owner}).Sort("name").All(&list)
+	if err != nil {
+		logger.Error("list folder failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// Rename 修改資料夾名稱
+func (f *FolderInfo) Rename(ctx context.Context, name string) (err error) {
+	err = folderColl.UpdateOne(ctx, bsonext.ID(f.Id), bsonext.Set(bson.M{"name": name}))
+	if err != nil {
+		return folderWriteErrConvert(err, "rename folder failed")
+	}
+
+	f.Name = name
+	return nil
+}
+
+// Move 將資料夾移動到 parent 中，parent 為空時移動到最上層
+//
+// 呼叫前需要確認 parent 不是此資料夾或其子資料夾
+func (f *FolderInfo) Move(ctx context.Context, parent primitive.ObjectID) (err error) {
+	update := bsonext.Set(bson.M{"parent": parent})
+	if parent.IsZero() {
+		update = bsonext.UnSet(bson.M{"parent": ""})
+	}
+	err = folderColl.UpdateOne(ctx, bsonext.ID(f.Id), update)
+	if err != nil {
+		return folderWriteErrConvert(err, "move folder failed")
+	}
+
+	f.Parent = parent
+	return nil
+}
+
+// FolderDelete 刪除資料夾，其中的 link 會被移動到最上層
+func FolderDelete(ctx context.Context, actor primitive.ObjectID, ids []primitive.ObjectID) (err error) {
+	err = transaction(ctx, func(sessCtx context.Context) error {
+		if _, err := folderColl.RemoveAll(sessCtx, bson.M{"_id": bsonext.In(ids)}); err != nil {
+			return err
+		}
+		_, err := linkUpdateAllWithHistory(sessCtx, HAFolderDelete, actor,
+			bson.M{"folder": bsonext.In(ids)}, bsonext.UnSet(bson.M{"folder": ""}), "folder")
+		return err
+	})
+	return txErrConvert(err, "delete folder failed")
+}
+
+// FolderClicks 資料夾中 link 的統計資料 (不包含子資料夾)
+type FolderClicks struct {
+	LinkNum     uint64 `bson:"linknum"`
+	TotalClicks uint64 `bson:"totalclicks"`
+}
+
+// FolderClicksAggreByOwner 以資料夾分組，統計使用者未被刪除的 link 數量與點擊次數
+func FolderClicksAggreByOwner(ctx context.Context, owner primitive.ObjectID) (
+	clicks map[primitive.ObjectID]FolderClicks, err error) {
+	type groupRes struct {
+		Folder       primitive.ObjectID `bson:"_id"`
+		FolderClicks `bson:",inline"`
+	}
+
+	var res []groupRes
+	err = linkColl.Aggregate(ctx,
+		[]bson.M{
+			bsonext.Match(bson.M{"creator": owner, "deleted": false, "folder": bson.M{"$exists": true}}),
+			bsonext.Group(bson.M{
+				"_id":         "$folder",
+				"linknum":     bson.M{"$sum": 1},
+				"totalclicks": bson.M{"$sum": "$totalclicks"},
+			}),
+		}).All(&res)
+	if err != nil {
+		logger.Error("aggregate folder clicks failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	clicks = make(map[primitive.ObjectID]FolderClicks, len(res))
+	for _, r := range res {
+		clicks[r.Folder] = r.FolderClicks
+	}
+	return clicks, nil
+}
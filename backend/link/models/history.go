@@ -0,0 +1,169 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"URLS/internal/utils/bsonext"
+	"context"
+	"reflect"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+const historyCollName string = "histories" + collSuffix
+
+var historyColl *qmgo.Collection
+
+func initHistoryCollIndex(ctx context.Context) (err error) {
+	err = historyColl.CreateOneIndex(ctx, options.IndexModel{Key: []string{"link", "-createAt"}})
+
+	return
+}
+
+// HistoryAction 對 link 的修改類型
+type HistoryAction string
+
+const (
+	HALinkCreate   HistoryAction = "create"   // 建立
+	HALinkPatch    HistoryAction = "patch"    // 修改 note、tags、資料夾
+	HALinkDelete   HistoryAction = "delete"   // 刪除
+	HALinkRestore  HistoryAction = "restore"  // 還原已刪除的 link
+	HALinkTransfer HistoryAction = "transfer" // 轉移擁有者
+	HATagsEdit     HistoryAction = "tags"     // 批次修改、刪除 tag
+	HAFolderDelete HistoryAction = "folder"   // 所在的資料夾被刪除
+)
+
+// LinkHistoryInfo link 的修改紀錄，只會新增不會修改
+type LinkHistoryInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Link   primitive.ObjectID `bson:"link"`
+	Action HistoryAction      `bson:"action"`
+	Actor  primitive.ObjectID `bson:"actor"`            // 執行修改的使用者
+	Before bson.M             `bson:"before,omitempty"` // 被修改的欄位在修改前的值，不存在時為 null
+	After  bson.M             `bson:"after,omitempty"`  // 被修改的欄位在修改後的值，被移除時為 null
+}
+
+// NewLinkHistory 建立 link 的修改紀錄
+func NewLinkHistory(link primitive.ObjectID, action HistoryAction, actor primitive.ObjectID,
+	before, after bson.M) *LinkHistoryInfo {
+	return &LinkHistoryInfo{
+		Link:   link,
+		Action: action,
+		Actor:  actor,
+		Before: before,
+		After:  after,
+	}
+}
+
+// LinkHistoryAdd 新增 link 的修改紀錄
+func LinkHistoryAdd(ctx context.Context, histories ...*LinkHistoryInfo) (err error) {
+	if len(histories) == 0 {
+		return nil
+	}
+
+	_, err = historyColl.InsertMany(ctx, histories)
+	if err != nil {
+		logger.Error("insert link history failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
+
+// LinkHistoryList 依時間由新到舊回傳 link 的修改紀錄
+func LinkHistoryList(ctx context.Context, link primitive.ObjectID, skip, limit int64) (
+	list []*LinkHistoryInfo, err error) {
+	err = historyColl.Find(ctx, bson.M{"link": link}).
+		Sort("-createAt", "-_id").Skip(skip).Limit(limit).All(&list)
+	if err != nil {
+		logger.Error("list link history failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// linkHistoryDiff 回傳 fields 中前後不同的欄位值
+func linkHistoryDiff(before, after bson.M, fields []string) (bDiff, aDiff bson.M) {
+	bDiff, aDiff = bson.M{}, bson.M{}
+	for _, f := range fields {
+		if !reflect.DeepEqual(before[f], after[f]) {
+			bDiff[f] = before[f]
+			aDiff[f] = after[f]
+		}
+	}
+	return
+}
+
+// linkUpdateAllWithHistory 以 update 更新所有符合 query 的 link，
+// 並為每個 fields 有改變的 link 新增修改紀錄，回傳被修改的 link 數量
+//
+// 需要在 transaction 中呼叫，避免只有更新或修改紀錄被寫入
+func linkUpdateAllWithHistory(ctx context.Context, action HistoryAction, actor primitive.ObjectID,
+	query bson.M, update interface{}, fields ...string) (modifiedNum int64, err error) {
+	projection := bson.M{"_id": 1}
+	for _, f := range fields {
+		projection[f] = 1
+	}
+
+	var beforeList []bson.M
+	err = linkColl.Find(ctx, query).Select(projection).All(&beforeList)
+	if err != nil {
+		logger.Error("find links before update failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+	if len(beforeList) == 0 {
+		return 0, nil
+	}
+	ids := make([]primitive.ObjectID, 0, len(beforeList))
+	for _, doc := range beforeList {
+		ids = append(ids, doc["_id"].(primitive.ObjectID))
+	}
+
+	// 只更新已經紀錄修改前資料的 link，不修改呼叫者的 query
+	updateQuery := make(bson.M, len(query)+1)
+	for k, v := range query {
+		updateQuery[k] = v
+	}
+	updateQuery["_id"] = bsonext.In(ids)
+	res, err := linkColl.UpdateAll(ctx, updateQuery, update)
+	if err != nil {
+		logger.Error("update links failed", zap.String("action", string(action)), zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	var afterList []bson.M
+	err = linkColl.Find(ctx, bson.M{"_id": bsonext.In(ids)}).Select(projection).All(&afterList)
+	if err != nil {
+		logger.Error("find links after update failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+	afterMap := make(map[primitive.ObjectID]bson.M, len(afterList))
+	for _, doc := range afterList {
+		afterMap[doc["_id"].(primitive.ObjectID)] = doc
+	}
+
+	histories := make([]*LinkHistoryInfo, 0, len(beforeList))
+	for _, before := range beforeList {
+		id := before["_id"].(primitive.ObjectID)
+		bDiff, aDiff := linkHistoryDiff(before, afterMap[id], fields)
+		if len(bDiff) > 0 {
+			histories = append(histories, NewLinkHistory(id, action, actor, bDiff, aDiff))
+		}
+	}
+	if err = LinkHistoryAdd(ctx, histories...); err != nil {
+		return
+	}
+
+	return res.ModifiedCount, nil
+}
@@ -1,23 +1,35 @@
 package models
 
 import (
+	"URLS/internal/common"
 	"context"
 
 	"github.com/qiniu/qmgo"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
 )
 
 const collSuffix string = "-link"
 
 var logger *zap.Logger
+var mgoClient *qmgo.Client
 var mgoDB *qmgo.Database
 
-func InitModels(ctx context.Context, db *qmgo.Database, inLogger *zap.Logger) (err error) {
+func InitModels(ctx context.Context, client *qmgo.Client, db *qmgo.Database, inLogger *zap.Logger) (err error) {
+	mgoClient = client
 	mgoDB = db
 	logger = inLogger
 
 	otherColl = mgoDB.Collection(otherCollName)
 	linkColl = mgoDB.Collection(linkCollName)
+	blockDomainColl = mgoDB.Collection(blockDomainCollName)
+	reservedWordColl = mgoDB.Collection(reservedWordCollName)
+	folderColl = mgoDB.Collection(folderCollName)
+	auditColl = mgoDB.Collection(auditCollName)
+	historyColl = mgoDB.Collection(historyCollName)
+	utmTemplateColl = mgoDB.Collection(utmTemplateCollName)
+	outboxColl = mgoDB.Collection(outboxCollName)
+	errorPageColl = mgoDB.Collection(errorPageCollName)
 
 	err = initIndex(ctx)
 	return
@@ -28,6 +40,14 @@ func initIndex(ctx context.Context) (err error) {
 	var initFuncList = []func(context.Context) error{
 		initOtherCollIndex,
 		initLinkCollIndex,
+		initBlockDomainCollIndex,
+		initReservedWordCollIndex,
+		initFolderCollIndex,
+		initAuditCollIndex,
+		initHistoryCollIndex,
+		initUTMTemplateCollIndex,
+		initOutboxCollIndex,
+		initErrorPageCollIndex,
 	}
 
 	for _, f := range initFuncList {
@@ -39,3 +59,26 @@ func initIndex(ctx context.Context) (err error) {
 
 	return
 }
+
+// transaction 在同一個 transaction 中執行 fn，fn 中的資料庫操作都需要使用 sessCtx
+//
+// mongo 需要是 replica set 才能使用 transaction，回傳的錯誤不會被轉換為 gRPC 錯誤
+func transaction(ctx context.Context, fn func(sessCtx context.Context) error) (err error) {
+	_, err = mgoClient.DoTransaction(ctx, func(sessCtx context.Context) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return
+}
+
+// txErrConvert 將 transaction 回傳的錯誤轉換為 gRPC 錯誤，fn 回傳的 gRPC 錯誤會直接回傳
+func txErrConvert(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	if _, isStatus := status.FromError(err); isStatus {
+		return err
+	}
+
+	logger.Error(msg, zap.Error(err))
+	return common.GRPCErrInternal
+}
@@ -3,8 +3,11 @@ package models
 import (
 	"URLS/internal/common"
 	"URLS/internal/utils/bsonext"
+	"URLS/link/pkg/codegen"
+	"URLS/link/pkg/passthrough"
 	linkPB "URLS/proto/gen/go/link/v1"
 	"context"
+	"net/http"
 	"net/url"
 	"sort"
 	"time"
@@ -12,7 +15,6 @@ import (
 	"github.com/qiniu/qmgo"
 	"github.com/qiniu/qmgo/field"
 	"github.com/qiniu/qmgo/options"
-	"github.com/speps/go-hashids/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -28,8 +30,6 @@ const linkCollName string = "links" + collSuffix
 
 var linkColl *qmgo.Collection
 
-var shortLinkHash *hashids.HashID
-
 func initLinkCollIndex(ctx context.Context) (err error) {
 	uniqueOpts := officialOpts.Index()
 	uniqueOpts.SetUnique(true)
@@ -43,34 +43,28 @@ func initLinkCollIndex(ctx context.Context) (err error) {
 	tagsOpts := officialOpts.Index()
 	tagsOpts.SetPartialFilterExpression(bson.M{"tags": bson.M{"$exists": true}})
 
+	workspaceOpts := officialOpts.Index()
+	workspaceOpts.SetPartialFilterExpression(bson.M{"workspace": bson.M{"$exists": true}})
+
+	folderOpts := officialOpts.Index()
+	folderOpts.SetPartialFilterExpression(bson.M{"folder": bson.M{"$exists": true}})
+
+	brokenOpts := officialOpts.Index()
+	brokenOpts.SetPartialFilterExpression(bson.M{"health.broken": bson.M{"$eq": true}})
+
 	err = linkColl.CreateIndexes(ctx, []options.IndexModel{
 		{Key: []string{"type"}, IndexOptions: typeOpts},
 		{Key: []string{"deleted"}, IndexOptions: deletedOpts},
 		{Key: []string{"short", "host"}, IndexOptions: uniqueOpts},
 		{Key: []string{"creator"}},
+		{Key: []string{"workspace"}, IndexOptions: workspaceOpts},
 		{Key: []string{"tags"}, IndexOptions: tagsOpts},
+		{Key: []string{"folder"}, IndexOptions: folderOpts},
 		{Key: []string{"totalclicks"}},
+		{Key: []string{"health.checkat"}},
+		{Key: []string{"health.broken"}, IndexOptions: brokenOpts},
 	})
-	if err != nil {
-		return
-	}
-
-	// init short link hashID
-
-	hashSlat, err := hashIDSlatGet(ctx)
-	if err != nil {
-		return
-	}
-
-	hd := hashids.NewData()
-	hd.Salt = hashSlat
-	hd.MinLength = 5
-	shortLinkHash, err = hashids.NewWithData(hd)
-	if err != nil {
-		return
-	}
-
-	return nil
+	return
 }
 
 // LinkType 短網址的類型
@@ -79,12 +73,13 @@ type LinkType int32
 const (
 	_        LinkType = iota
 	LTDirect          // 直接導向
+	LTPrefix          // 短網址只比對第一段路徑，其餘的路徑會被加到目的地網址後面
 )
 
 func LinkTypeFromInteger[T constraints.Integer](i T) (LinkType, bool) {
 	conv := LinkType(i)
 	switch conv {
-	case LTDirect:
+	case LTDirect, LTPrefix:
 		return conv, true
 	default:
 		return 0, false
@@ -109,6 +104,39 @@ func UTMInfoFromPB(info *linkPB.UTMInfo) *UTMInfo {
 	}
 }
 
+// ToPB 轉換為 protobuf 的 UTMInfo
+func (u *UTMInfo) ToPB() *linkPB.UTMInfo {
+	return &linkPB.UTMInfo{
+		Source:   u.Source,
+		Medium:   u.Medium,
+		Campaign: u.Campaign,
+		Term:     u.Term,
+		Content:  u.Content,
+	}
+}
+
+// Override 回傳以 o 中不為空的欄位覆蓋 u 後的結果
+func (u *UTMInfo) Override(o *UTMInfo) *UTMInfo {
+	res := *u
+	if o.Source != "" {
+		res.Source = o.Source
+	}
+	if o.Medium != "" {
+		res.Medium = o.Medium
+	}
+	if o.Campaign != "" {
+		res.Campaign = o.Campaign
+	}
+	if o.Term != "" {
+		res.Term = o.Term
+	}
+	if o.Content != "" {
+		res.Content = o.Content
+	}
+
+	return &res
+}
+
 func (u *UTMInfo) ConvertToMap() (res map[string]string) {
 	const utmColumNum = 5
 	res = make(map[string]string, utmColumNum)
@@ -144,8 +172,14 @@ type LinkInfo struct {
 	Querys   map[string]string  `bson:"querys,omitempty"` // 自定義參數
 	Creator  primitive.ObjectID `bson:"creator"`          // 建立者
 
-	Note string   `bson:"note"`           // 備註訊息
-	Tags []string `bson:"tags,omitempty"` // 標籤
+	Passthrough  *LinkPassthroughInfo `bson:"passthrough,omitempty"`  // 為空時不傳遞造訪時的 query
+	RedirectCode int                  `bson:"redirectcode,omitempty"` // 導向時的 HTTP 狀態碼，為 0 時使用 redirector 的預設值
+
+	Workspace primitive.ObjectID `bson:"workspace,omitempty"` // 所屬的工作區，個人的短網址為空
+
+	Note   string             `bson:"note"`             // 備註訊息
+	Tags   []string           `bson:"tags,omitempty"`   // 標籤
+	Folder primitive.ObjectID `bson:"folder,omitempty"` // 所在的資料夾，最上層時為空
 
 	TotalClicks   uint64            `bson:"totalclicks"`             // 總點擊次數
 	CountryClicks map[string]uint64 `bson:"countryclicks,omitempty"` // 國家來源 map[ISO3166]count
@@ -153,9 +187,70 @@ type LinkInfo struct {
 	DeviceClicks  map[string]uint64 `bson:"deviceclicks,omitempty"`  // 裝置來源 map[(pc、tablet、phone ...)]count
 	BrowserClicks map[string]uint64 `bson:"browserclicks,omitempty"` // 瀏覽器來源
 
+	Health LinkHealthInfo `bson:"health"` // 目的地的健康檢查結果
+
 	DeleteAt time.Time `bson:"deleteAt,omitempty"` // 被刪除的時間
 }
 
+// RedirectCodeValid 回傳 code 是否為可以使用的導向狀態碼
+func RedirectCodeValid(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// LinkPassthroughInfo 將造訪時的 query 合併到目的地網址的設定
+type LinkPassthroughInfo struct {
+	Conflict passthrough.Conflict `bson:"conflict"`        // 與目的地網址中的參數同名時的處理方式
+	Allow    []string             `bson:"allow,omitempty"` // 允許傳遞的參數名稱，為空時允許所有參數
+}
+
+// Policy 轉換為合併 query 的規則，p 為 nil 時回傳 nil
+func (p *LinkPassthroughInfo) Policy() *passthrough.Policy {
+	if p == nil {
+		return nil
+	}
+	return &passthrough.Policy{Conflict: p.Conflict, Allow: p.Allow}
+}
+
+// LinkHealthInfo 目的地的健康檢查結果
+type LinkHealthInfo struct {
+	StatusCode int       `bson:"status,omitempty"`  // HTTP 狀態碼，無法連線時為 0
+	LatencyMS  int64     `bson:"latency,omitempty"` // 回應時間(毫秒)
+	Broken     bool      `bson:"broken"`            // 目的地是否無法正常使用
+	CheckAt    time.Time `bson:"checkat,omitempty"` // 最後一次檢查的時間
+}
+
+// HistoryValues 回傳建立 link 時要紀錄的欄位值
+func (l *LinkInfo) HistoryValues() bson.M {
+	values := bson.M{
+		"short":    l.Short,
+		"host":     l.Host,
+		"type":     l.Type,
+		"dest":     l.FullDest(),
+		"iscustom": l.IsCustom,
+		"note":     l.Note,
+		"tags":     l.Tags,
+	}
+	if !l.Folder.IsZero() {
+		values["folder"] = l.Folder
+	}
+	if !l.Workspace.IsZero() {
+		values["workspace"] = l.Workspace
+	}
+	if l.Passthrough != nil {
+		values["passthrough"] = l.Passthrough
+	}
+	if l.RedirectCode != 0 {
+		values["redirectcode"] = l.RedirectCode
+	}
+	return values
+}
+
 // FullDest 回傳包含 query 的目的地網址
 func (l *LinkInfo) FullDest() string {
 	u, _ := url.Parse(l.Dest)
@@ -174,48 +269,80 @@ func (l *LinkInfo) FullDest() string {
 	return u.String()
 }
 
+// genShortMaxRetry 自動產生的短網址與已存在的短網址重複時，最多重新產生的次數
+const genShortMaxRetry = 5
+
+// LinkCreateInfo 建立短網址時使用的資料
+type LinkCreateInfo struct {
+	Type      LinkType
+	Custom    string // 客製化短網址，為空時自動產生
+	Host      string
+	Dest      string
+	UTMInfo   *UTMInfo
+	Creator   primitive.ObjectID
+	Workspace primitive.ObjectID // 所屬的工作區，個人的短網址為空
+	Note      string
+	Tags      []string
+	Folder    primitive.ObjectID
+
+	Passthrough  *LinkPassthroughInfo
+	RedirectCode int // 為 0 時使用 redirector 的預設值
+}
+
 // LinkCreate 根據指定資料建立短網址到資料庫
-func LinkCreate(ctx context.Context, custom, host, dest string,
-	utmInfo *UTMInfo, creator primitive.ObjectID, note string, tags []string) (
-	*LinkInfo, error) {
-	var isCustom bool
-	var short string
-	var err error
-	if custom == "" {
-		var encVal []int64
-		encVal, err = LinkCounterNext(ctx)
-		if err != nil {
-			return nil, err
-		}
-		short, err = shortLinkHash.EncodeInt64(encVal)
-		if err != nil {
-			logger.Error("shortLinkHash.EncodeInt64 faeild", zap.Int64s("enc value", encVal), zap.Error(err))
-			err = common.GRPCErrInternal
-			return nil, err
+//
+// Custom 為空時使用 gen 產生短網址，產生的短網址已存在時會重新產生，
+// inTx 與新增 link 在同一個 transaction 中執行，其中的資料庫操作都需要使用 sessCtx
+func LinkCreate(ctx context.Context, cInfo *LinkCreateInfo, gen codegen.Generator,
+	inTx func(sessCtx context.Context, link *LinkInfo) error) (*LinkInfo, error) {
+	newLink := LinkInfo{
+		Type:      cInfo.Type,
+		IsCustom:  cInfo.Custom != "",
+		Host:      cInfo.Host,
+		Short:     cInfo.Custom,
+		Dest:      cInfo.Dest,
+		Creator:   cInfo.Creator,
+		Workspace: cInfo.Workspace,
+		Querys:    cInfo.UTMInfo.ConvertToMap(),
+		Note:      cInfo.Note,
+		Tags:      cInfo.Tags,
+		Folder:    cInfo.Folder,
+
+		Passthrough:  cInfo.Passthrough,
+		RedirectCode: cInfo.RedirectCode,
+	}
+
+	for retry := 0; ; retry++ {
+		if !newLink.IsCustom {
+			short, err := gen.Generate(ctx)
+			if err != nil {
+				logger.Error("generate short link failed", zap.Error(err))
+				return nil, common.GRPCErrInternal
+			}
+			newLink.Short = short
 		}
-	} else {
-		short = custom
-		isCustom = true
-	}
 
-	newLink := LinkInfo{
-		Type:     LTDirect,
-		IsCustom: isCustom,
-		Host:     host,
-		Short:    short,
-		Dest:     dest,
-		Creator:  creator,
-		Querys:   utmInfo.ConvertToMap(),
-		Note:     note,
-		Tags:     tags,
-	}
-	_, err = linkColl.InsertOne(ctx, &newLink)
-	if err != nil {
+		err := transaction(ctx, func(sessCtx context.Context) error {
+			if _, err := linkColl.InsertOne(sessCtx, &newLink); err != nil {
+				return err
+			}
+			return inTx(sessCtx, &newLink)
+		})
+		if err == nil {
+			break
+		}
 		if mongo.IsDuplicateKeyError(err) {
+			if !newLink.IsCustom && retry < genShortMaxRetry {
+				logger.Warn("generated short link already exists, retry", zap.String("short", newLink.Short))
+				continue
+			}
 			// 短網址(short)已有相同的
 			err = status.Error(codes.AlreadyExists, "this link already exists")
 			return nil, err
 		}
+		if _, isStatus := status.FromError(err); isStatus {
+			return nil, err
+		}
 		logger.Error("new link insert to db faeild", zap.Error(err))
 		err = common.GRPCErrInternal
 		return nil, err
@@ -244,23 +371,53 @@ func LinkFindByID(ctx context.Context, id primitive.ObjectID) (link *LinkInfo, e
 	return
 }
 
-// LinkListCount 回傳根據條件會搜尋到的資料總數
-func LinkListCount(ctx context.Context,
-	allUser bool, userID primitive.ObjectID,
-	tags []string) (totalNum int64, err error) {
-	query := bson.M{
-		"deleted": false,
+// LinkFindByShort 根據 (short, host) 尋找 link
+func LinkFindByShort(ctx context.Context, short, host string) (link *LinkInfo, exist bool, err error) {
+	link = new(LinkInfo)
+	err = linkColl.Find(ctx, bson.M{"short": short, "host": host}).One(&link)
+	if err != nil {
+		link = nil
+		if qmgo.IsErrNoDocuments(err) {
+			exist = false
+			err = nil
+			return
+		}
+		logger.Error("find link by short failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	exist = true
+	return
+}
+
+// LinkShortsExist 回傳 shorts 中已在 host 上被使用的短網址
+func LinkShortsExist(ctx context.Context, host string, shorts []string) (exist map[string]struct{}, err error) {
+	var used []string
+	err = linkColl.Find(ctx, bson.M{"host": host, "short": bson.M{"$in": shorts}}).Distinct("short", &used)
+	if err != nil {
+		logger.Error("distinct link short failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
 	}
-	if len(tags) > 0 {
-		query["tags"] = bsonext.In(tags)
+
+	exist = make(map[string]struct{}, len(used))
+	for _, short := range used {
+		exist[short] = struct{}{}
 	}
-	if !allUser {
-		query["creator"] = userID
+	return exist, nil
+}
+
+// LinkListAfterID 依 id 由小到大回傳 id 大於 after 的 link (包含已刪除的)，after 為空時從頭開始
+func LinkListAfterID(ctx context.Context, after primitive.ObjectID, limit int64) (list []*LinkInfo, err error) {
+	query := bson.M{}
+	if !after.IsZero() {
+		query["_id"] = bson.M{"$gt": after}
 	}
 
-	totalNum, err = linkColl.Find(ctx, query).Count()
+	err = linkColl.Find(ctx, query).Sort("_id").Limit(limit).All(&list)
 	if err != nil {
-		logger.Error("get list link count failed", zap.Error(err))
+		logger.Error("list link after id failed", zap.Error(err))
 		err = common.GRPCErrInternal
 		return
 	}
@@ -268,25 +425,76 @@ func LinkListCount(ctx context.Context,
 	return
 }
 
-// LinkList 根據條件回傳 link 的資料
-func LinkList(ctx context.Context,
-	allUser bool, userID primitive.ObjectID,
-	sortBy string, reverse bool, tags []string,
-	skip int64, limit int64) (linkList []*LinkInfo, err error) {
+// LinkHostList 回傳短網址使用到的所有 host (不包含預設的空 host)
+func LinkHostList(ctx context.Context) (hosts []string, err error) {
+	err = linkColl.Find(ctx, bson.M{"host": bson.M{"$ne": ""}}).Distinct("host", &hosts)
+	if err != nil {
+		logger.Error("distinct link host failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// personalLinkQuery 使用者建立的個人 link (不包含工作區的 link)
+func personalLinkQuery(userID primitive.ObjectID) bson.M {
+	return bson.M{"creator": userID, "workspace": bson.M{"$exists": false}}
+}
+
+// LinkListFilter 查詢 link 列表時的條件
+type LinkListFilter struct {
+	AllUser    bool               // 是否查詢所有使用者
+	UserID     primitive.ObjectID // AllUser 為 false 時只查詢此使用者建立的個人 link
+	Workspace  primitive.ObjectID // 不為空時查詢此工作區的 link，忽略 AllUser 與 UserID
+	Tags       []string           // 包含任一 tag
+	OnlyBroken bool               // 只查詢健康檢查失敗的 link
+	Folder     primitive.ObjectID // 不為空時只查詢此資料夾中的 link (不包含子資料夾)
+}
+
+func (f *LinkListFilter) toQuery() bson.M {
 	query := bson.M{
 		"deleted": false,
 	}
-	if !allUser {
-		query["creator"] = userID
+	if !f.Workspace.IsZero() {
+		query["workspace"] = f.Workspace
+	} else if !f.AllUser {
+		maps.Copy(query, personalLinkQuery(f.UserID))
 	}
-	if len(tags) > 0 {
-		query["tags"] = bsonext.In(tags)
+	if len(f.Tags) > 0 {
+		query["tags"] = bsonext.In(f.Tags)
 	}
+	if f.OnlyBroken {
+		query["health.broken"] = true
+	}
+	if !f.Folder.IsZero() {
+		query["folder"] = f.Folder
+	}
+
+	return query
+}
+
+// LinkListCount 回傳根據條件會搜尋到的資料總數
+func LinkListCount(ctx context.Context, filter *LinkListFilter) (totalNum int64, err error) {
+	totalNum, err = linkColl.Find(ctx, filter.toQuery()).Count()
+	if err != nil {
+		logger.Error("get list link count failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// LinkList 根據條件回傳 link 的資料
+func LinkList(ctx context.Context, filter *LinkListFilter,
+	sortBy string, reverse bool,
+	skip int64, limit int64) (linkList []*LinkInfo, err error) {
 	if reverse {
 		sortBy = "-" + sortBy
 	}
 
-	err = linkColl.Find(ctx, query).Sort(sortBy).Skip(skip).Limit(limit).All(&linkList)
+	err = linkColl.Find(ctx, filter.toQuery()).Sort(sortBy).Skip(skip).Limit(limit).All(&linkList)
 	if err != nil {
 		logger.Error("list link failed", zap.Error(err))
 		err = common.GRPCErrInternal
@@ -296,6 +504,120 @@ func LinkList(ctx context.Context,
 	return
 }
 
+// LinkTransferFilter 要轉移的 link，LinkID 與 Tags 都為空時轉移所有個人 link
+type LinkTransferFilter struct {
+	From   primitive.ObjectID // 原本的建立者
+	LinkID primitive.ObjectID // 只轉移此 link
+	Tags   []string           // 只轉移包含任一 tag 的 link
+}
+
+func (f *LinkTransferFilter) toQuery() bson.M {
+	query := personalLinkQuery(f.From)
+	query["deleted"] = false
+	if !f.LinkID.IsZero() {
+		query["_id"] = f.LinkID
+	}
+	if len(f.Tags) > 0 {
+		query["tags"] = bsonext.In(f.Tags)
+	}
+
+	return query
+}
+
+// LinkTransfer 將符合條件且未被刪除的個人 link 轉移給 to，短網址不會改變
+//
+// 資料夾屬於原本的建立者，轉移後的 link 會被移動到最上層，
+// inTx 與轉移在同一個 transaction 中執行，收到被轉移的 link 與其中客製化短網址的數量，
+// 其中的資料庫操作都需要使用 sessCtx，回傳錯誤時不會轉移任何 link
+func LinkTransfer(ctx context.Context, actor primitive.ObjectID, filter *LinkTransferFilter, to primitive.ObjectID,
+	inTx func(sessCtx context.Context, ids []primitive.ObjectID, customNum int64) error) (
+	ids []primitive.ObjectID, customNum int64, err error) {
+	type selectRes struct {
+		Id       primitive.ObjectID `bson:"_id"`
+		IsCustom bool               `bson:"iscustom"`
+	}
+
+	err = transaction(ctx, func(sessCtx context.Context) error {
+		var res []selectRes
+		err := linkColl.Find(sessCtx, filter.toQuery()).Select(bson.M{"_id": 1, "iscustom": 1}).All(&res)
+		if err != nil {
+			return err
+		}
+
+		ids, customNum = make([]primitive.ObjectID, 0, len(res)), 0
+		for _, r := range res {
+			ids = append(ids, r.Id)
+			if r.IsCustom {
+				customNum++
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		_, err = linkUpdateAllWithHistory(sessCtx, HALinkTransfer, actor,
+			bson.M{"_id": bsonext.In(ids), "creator": filter.From, "deleted": false},
+			bson.M{
+				"$set":   bson.M{"creator": to},
+				"$unset": bson.M{"folder": ""},
+			},
+			"creator", "folder")
+		if err != nil {
+			return err
+		}
+
+		return inTx(sessCtx, ids, customNum)
+	})
+	if err = txErrConvert(err, "transfer links failed"); err != nil {
+		return nil, 0, err
+	}
+
+	return ids, customNum, nil
+}
+
+// LinkHealthClaim 取得一個需要進行健康檢查的 link，並將其檢查時間設為現在，避免被其他 service 重複檢查
+//
+// checkBefore 之前檢查過或從未檢查過的 link 才會被取得
+func LinkHealthClaim(ctx context.Context, checkBefore time.Time) (link *LinkInfo, exist bool, err error) {
+	link = new(LinkInfo)
+	err = linkColl.Find(ctx, bson.M{
+		"deleted": false,
+		"$or": []bson.M{
+			{"health.checkat": bson.M{"$lt": checkBefore}},
+			{"health.checkat": bson.M{"$exists": false}},
+		},
+	}).Apply(qmgo.Change{
+		Update:    bsonext.Set(bson.M{"health.checkat": time.Now()}),
+		ReturnNew: true,
+	}, link)
+	if err != nil {
+		link = nil
+		if qmgo.IsErrNoDocuments(err) {
+			err = nil
+			return
+		}
+		logger.Error("claim link health check failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	exist = true
+	return
+}
+
+// HealthUpdate 更新健康檢查結果
+func (l *LinkInfo) HealthUpdate(ctx context.Context, health *LinkHealthInfo) (err error) {
+	err = linkColl.UpdateOne(ctx, bsonext.ID(l.Id), bsonext.Set(bson.M{"health": health}))
+	if err != nil {
+		logger.Error("update link health failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	l.Health = *health
+	return
+}
+
 func LinkClicksUpdate(ctx context.Context, short, host string, total uint64,
 	country map[string]uint64,
 	os map[string]uint64,
@@ -327,70 +649,171 @@ func LinkClicksUpdate(ctx context.Context, short, host string, total uint64,
 }
 
 type LinkPatchInfo struct {
-	PNote bool
-	Note  string
-	PTags bool
-	Tags  []string
+	PNote   bool
+	Note    string
+	PTags   bool
+	Tags    []string
+	PFolder bool
+	Folder  primitive.ObjectID // 為空時移動到最上層
+
+	PPassthrough bool
+	Passthrough  *LinkPassthroughInfo // 為空時關閉
+
+	PRedirectCode bool
+	RedirectCode  int // 為 0 時使用 redirector 的預設值
 }
 
-func (l *LinkInfo) Patch(ctx context.Context, pInfo *LinkPatchInfo) (err error) {
+// HistoryValues 回傳 l 被修改的欄位在修改前後的值
+func (pInfo *LinkPatchInfo) HistoryValues(l *LinkInfo) (before, after bson.M) {
+	folderValue := func(folder primitive.ObjectID) interface{} {
+		if folder.IsZero() {
+			return nil
+		}
+		return folder
+	}
+
+	before, after = bson.M{}, bson.M{}
+	if pInfo.PNote {
+		before["note"], after["note"] = l.Note, pInfo.Note
+	}
+	if pInfo.PTags {
+		before["tags"], after["tags"] = l.Tags, pInfo.Tags
+	}
+	if pInfo.PFolder {
+		before["folder"], after["folder"] = folderValue(l.Folder), folderValue(pInfo.Folder)
+	}
+	if pInfo.PPassthrough {
+		before["passthrough"], after["passthrough"] = l.Passthrough, pInfo.Passthrough
+	}
+	if pInfo.PRedirectCode {
+		before["redirectcode"], after["redirectcode"] = l.RedirectCode, pInfo.RedirectCode
+	}
+	return
+}
+
+// Patch 修改 link，並在同一個 transaction 中新增 actor 的修改紀錄
+func (l *LinkInfo) Patch(ctx context.Context, actor primitive.ObjectID, pInfo *LinkPatchInfo) (err error) {
+	before, after := pInfo.HistoryValues(l)
+
 	updateCol := bson.M{}
+	unsetCol := bson.M{}
 	if pInfo.PNote {
 		updateCol["note"] = pInfo.Note
 	}
 	if pInfo.PTags {
 		updateCol["tags"] = pInfo.Tags
 	}
+	if pInfo.PFolder {
+		if pInfo.Folder.IsZero() {
+			unsetCol["folder"] = ""
+		} else {
+			updateCol["folder"] = pInfo.Folder
+		}
+	}
+	if pInfo.PPassthrough {
+		if pInfo.Passthrough == nil {
+			unsetCol["passthrough"] = ""
+		} else {
+			updateCol["passthrough"] = pInfo.Passthrough
+		}
+	}
+	if pInfo.PRedirectCode {
+		if pInfo.RedirectCode == 0 {
+			unsetCol["redirectcode"] = ""
+		} else {
+			updateCol["redirectcode"] = pInfo.RedirectCode
+		}
+	}
 
-	err = linkColl.UpdateOne(ctx, bsonext.ID(l.Id),
-		bsonext.Set(updateCol))
-	if err != nil {
-		logger.Error("patch link failed", zap.Error(err))
-		err = common.GRPCErrInternal
+	update := bson.M{}
+	if len(updateCol) > 0 {
+		update["$set"] = updateCol
+	}
+	if len(unsetCol) > 0 {
+		update["$unset"] = unsetCol
+	}
+	err = transaction(ctx, func(sessCtx context.Context) error {
+		if err := linkColl.UpdateOne(sessCtx, bsonext.ID(l.Id), update); err != nil {
+			return err
+		}
+		return LinkHistoryAdd(sessCtx, NewLinkHistory(l.Id, HALinkPatch, actor, before, after))
+	})
+	if err = txErrConvert(err, "patch link failed"); err != nil {
 		return
 	}
 
+	if pInfo.PNote {
+		l.Note = pInfo.Note
+	}
+	if pInfo.PTags {
+		l.Tags = pInfo.Tags
+	}
+	if pInfo.PFolder {
+		l.Folder = pInfo.Folder
+	}
+	if pInfo.PPassthrough {
+		l.Passthrough = pInfo.Passthrough
+	}
+	if pInfo.PRedirectCode {
+		l.RedirectCode = pInfo.RedirectCode
+	}
 	return
 }
 
-func (l *LinkInfo) Delete(ctx context.Context) (err error) {
-	err = linkColl.UpdateOne(ctx, bsonext.ID(l.Id),
-		bsonext.Set(bson.M{"deleted": true, "deleteAt": time.Now()}))
-	if err != nil {
-		logger.Error("delete link failed", zap.Error(err))
-		err = common.GRPCErrInternal
+// Delete 設為已被刪除，並在同一個 transaction 中新增 actor 的修改紀錄
+func (l *LinkInfo) Delete(ctx context.Context, actor primitive.ObjectID) (err error) {
+	err = transaction(ctx, func(sessCtx context.Context) error {
+		err := linkColl.UpdateOne(sessCtx, bsonext.ID(l.Id),
+			bsonext.Set(bson.M{"deleted": true, "deleteAt": time.Now()}))
+		if err != nil {
+			return err
+		}
+		return LinkHistoryAdd(sessCtx, NewLinkHistory(l.Id, HALinkDelete, actor,
+			bson.M{"deleted": false}, bson.M{"deleted": true}))
+	})
+	if err = txErrConvert(err, "delete link failed"); err != nil {
 		return
 	}
 
+	l.Deleted = true
 	return
 }
 
-// SetNoDelete 設為未被刪除
-func (l *LinkInfo) SetNoDelete(ctx context.Context) (err error) {
-	err = linkColl.UpdateOne(ctx, bsonext.ID(l.Id),
-		bson.M{
-			"$set":   bson.M{"deleted": false},
-			"$unset": bson.M{"deleteAt": ""},
-		})
-	if err != nil {
-		logger.Error("delete link failed", zap.Error(err))
-		err = common.GRPCErrInternal
+// SetNoDelete 設為未被刪除，並在同一個 transaction 中新增 actor 的修改紀錄
+func (l *LinkInfo) SetNoDelete(ctx context.Context, actor primitive.ObjectID) (err error) {
+	err = transaction(ctx, func(sessCtx context.Context) error {
+		err := linkColl.UpdateOne(sessCtx, bsonext.ID(l.Id),
+			bson.M{
+				"$set":   bson.M{"deleted": false},
+				"$unset": bson.M{"deleteAt": ""},
+			})
+		if err != nil {
+			return err
+		}
+		return LinkHistoryAdd(sessCtx, NewLinkHistory(l.Id, HALinkRestore, actor,
+			bson.M{"deleted": true}, bson.M{"deleted": false}))
+	})
+	if err = txErrConvert(err, "restore link failed"); err != nil {
 		return
 	}
 
+	l.Deleted = false
 	return
 }
 
-// TagsAggreByUser 回傳指定 user 的所有 tags
+// TagsAggreByUser 回傳指定 user 個人 link 的所有 tags
 func TagsAggreByUser(ctx context.Context, userID primitive.ObjectID) (tags []string, err error) {
 	type projectRes struct {
 		Tags []string `bson:"tags"`
 	}
 
+	query := personalLinkQuery(userID)
+	query["deleted"] = false
+
 	var res projectRes
 	err = linkColl.Aggregate(ctx,
 		[]bson.M{
-			bsonext.Match(bson.M{"creator": userID, "deleted": false}),
+			bsonext.Match(query),
 			bsonext.Group(bson.M{"_id": nil, "tags": bsonext.Push("$tags")}),
 			bsonext.Project(bson.M{"_id": false, "tags": bsonext.Reduce("$tags", []string{}, bsonext.SetUnion([]string{"$$value", "$$this"}))}),
 		}).One(&res)
@@ -407,3 +830,44 @@ func TagsAggreByUser(ctx context.Context, userID primitive.ObjectID) (tags []str
 	tags = res.Tags
 	return
 }
+
+// TagsReplace 將使用者所有個人 link 中屬於 from 的 tag 替換為 to，並保留 tag 的順序
+//
+// 替換後重複的 tag 只會保留第一個，回傳被修改的 link 數量
+func TagsReplace(ctx context.Context, userID primitive.ObjectID, from []string, to string) (
+	modifiedNum int64, err error) {
+	replaced := bson.M{"$map": bson.M{
+		"input": "$tags",
+		"in":    bson.M{"$cond": bson.A{bson.M{"$in": bson.A{"$$this", from}}, to, "$$this"}},
+	}}
+	dedup := bsonext.Reduce(replaced, bson.A{}, bson.M{"$cond": bson.A{
+		bson.M{"$in": bson.A{"$$this", "$$value"}},
+		"$$value",
+		bson.M{"$concatArrays": bson.A{"$$value", bson.A{"$$this"}}},
+	}})
+
+	query := personalLinkQuery(userID)
+	query["tags"] = bsonext.In(from)
+
+	err = transaction(ctx, func(sessCtx context.Context) (err error) {
+		modifiedNum, err = linkUpdateAllWithHistory(sessCtx, HATagsEdit, userID, query,
+			bson.A{bsonext.Set(bson.M{"tags": dedup})}, "tags")
+		return
+	})
+	err = txErrConvert(err, "replace tags failed")
+	return
+}
+
+// TagRemove 從使用者所有個人 link 中移除 tag，回傳被修改的 link 數量
+func TagRemove(ctx context.Context, userID primitive.ObjectID, tag string) (modifiedNum int64, err error) {
+	query := personalLinkQuery(userID)
+	query["tags"] = tag
+
+	err = transaction(ctx, func(sessCtx context.Context) (err error) {
+		modifiedNum, err = linkUpdateAllWithHistory(sessCtx, HATagsEdit, userID, query,
+			bsonext.Pull(bson.M{"tags": tag}), "tags")
+		return
+	})
+	err = txErrConvert(err, "remove tag failed")
+	return
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"URLS/link/pkg/codegen"
+	"URLS/link/pkg/counterlease"
+
+	"github.com/qiniu/qmgo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// benchMgoURIEnv 執行 BenchmarkLinkCreate 使用的 mongo 連線位址，需要是 replica set，未設定時略過
+const benchMgoURIEnv = "URLS_BENCH_MGO_URI"
+
+// benchmarkLinkCreate 以 LinkCounterLease 租用計數器、hashids 產生短網址並以 LinkCreate 寫入 mongo
+//
+// blockSize 為 1 時每個短網址都需要更新一次計數器文件，等同於租用之前的方式
+func benchmarkLinkCreate(b *testing.B, blockSize int64) {
+	uri := os.Getenv(benchMgoURIEnv)
+	if uri == "" {
+		b.Skip(benchMgoURIEnv + " is not set")
+	}
+
+	ctx := context.Background()
+	client, err := qmgo.NewClient(ctx, &qmgo.Config{Uri: uri})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close(ctx)
+	db := client.Database("urls_bench_" + primitive.NewObjectID().Hex())
+	defer db.DropDatabase(ctx)
+	if err = InitModels(ctx, client, db, zap.NewNop()); err != nil {
+		b.Fatal(err)
+	}
+
+	slat, err := HashIDSlatGet(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	counter := counterlease.New(LinkCounterLease, blockSize)
+	gen, err := codegen.NewHashIDs(counter.Next, slat, "", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	creator := primitive.NewObjectID()
+	noop := func(context.Context, *LinkInfo) error { return nil }
+
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			_, err := LinkCreate(ctx, &LinkCreateInfo{
+				Type:    LTDirect,
+				Dest:    "https://example.com/" + strconv.Itoa(i),
+				Creator: creator,
+			}, gen, noop)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkLinkCreateBlock1(b *testing.B)    { benchmarkLinkCreate(b, 1) }
+func BenchmarkLinkCreateBlock100(b *testing.B)  { benchmarkLinkCreate(b, 100) }
+func BenchmarkLinkCreateBlock1000(b *testing.B) { benchmarkLinkCreate(b, 1000) }
@@ -3,6 +3,7 @@ package models
 import (
 	"URLS/internal/utils/bsonext"
 	"URLS/internal/utils/bytesext"
+	"URLS/link/pkg/counterlease"
 	"context"
 	"encoding/base64"
 	"math"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/qiniu/qmgo"
 	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
 	officialOpts "go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
@@ -113,47 +115,70 @@ func linkCounterCreate(ctx context.Context) (err error) {
 	return
 }
 
-// LinkCounterNext 回傳下一個 link counter 的值
-func LinkCounterNext(ctx context.Context) (val []int64, err error) {
+// LinkCounterLease 向資料庫租用 size 個連續的 link counter 值
+//
+// value.0 增加 size 後，租用的區塊為 [value.0-size+1, value.0]，高位數值為 value[1:]，
+// 每個 link service 在記憶體中分配租用到的值，避免每次產生短網址都需要更新同一份文件
+func LinkCounterLease(ctx context.Context, size int64) (block counterlease.Block, err error) {
 	res := linkCounter{}
 	err = otherColl.Find(ctx, bsonext.Name(linkCounterName)).
 		Apply(qmgo.Change{
-			Update:    bsonext.Inc([]bsonext.IncInfo{{FieldName: "value.0", Val: 1}}),
+			Update:    bsonext.Inc([]bsonext.IncInfo{{FieldName: "value.0", Val: size}}),
 			ReturnNew: true,
 		}, &res)
 	if err != nil {
 		logger.Error("inc link counter failed", zap.Error(err))
 		return
 	}
-	val = res.Value
+
+	block = counterlease.Block{
+		Start: res.Value[0] - size + 1,
+		End:   res.Value[0],
+		Upper: res.Value[1:],
+	}
 
 	// 檢查 counter 是否到達進位標準
 	// 如果到達時需要將 counter 做進位處理
-	needUpdate := false
-	valLen := len(res.Value)
-	diffList := make([]int64, valLen+1)
-	for i := 0; i < valLen; i++ {
-		if res.Value[i]+diffList[i] > linkCounterCarryBase {
-			needUpdate = true
-			diffList[i] = res.Value[i] * -1
-			diffList[i+1]++
+	if res.Value[0] > linkCounterCarryBase {
+		if err = linkCounterCarry(ctx, res.Value); err != nil {
+			logger.Error("link counter deal failed", zap.Error(err))
 		}
 	}
-	if needUpdate {
-		carryList := make([]bsonext.IncInfo, valLen+1)
-		for i := 0; i < valLen+1; i++ {
-			if diffList[i] != 0 {
-				carryList[i].FieldName = "value." + strconv.Itoa(i)
-				carryList[i].Val = diffList[i]
-			}
+
+	return block, nil
+}
+
+// linkCounterCarry 將 value.0 歸零並進位到高位數值
+//
+// 只有在高位數值與 observed 相同時才會更新 (CAS)，
+// 多個 link service 同時進位時只有一個會成功，其他的已經被進位而不需要處理
+func linkCounterCarry(ctx context.Context, observed []int64) (err error) {
+	carried := make([]int64, len(observed), len(observed)+1)
+	copy(carried, observed)
+	carried[0] = 0
+	for i := 1; ; i++ {
+		if i == len(carried) {
+			carried = append(carried, 1)
+			break
 		}
-		err = otherColl.UpdateOne(ctx, bsonext.Name(linkCounterName), bsonext.Inc(carryList))
-		if err != nil {
-			logger.Error("link counter deal failed", zap.Error(err))
+		carried[i]++
+		if carried[i] <= linkCounterCarryBase {
+			break
 		}
+		carried[i] = 0
 	}
 
-	return val, nil
+	filter := bsonext.Name(linkCounterName)
+	for i := 1; i < len(observed); i++ {
+		filter["value."+strconv.Itoa(i)] = observed[i]
+	}
+	filter["value."+strconv.Itoa(len(observed))] = bson.M{"$exists": false}
+
+	err = otherColl.UpdateOne(ctx, filter, bsonext.Set(bson.M{"value": carried}))
+	if qmgo.IsErrNoDocuments(err) {
+		return nil
+	}
+	return err
 }
 
 // settingInfo
@@ -192,7 +217,8 @@ func hashIDSlatCreate(ctx context.Context) (err error) {
 	return settingCreate(ctx, hashIDSlatName, base64.StdEncoding.EncodeToString(randBs))
 }
 
-func hashIDSlatGet(ctx context.Context) (slat string, err error) {
+// HashIDSlatGet 回傳 hashids 使用的 slat
+func HashIDSlatGet(ctx context.Context) (slat string, err error) {
 	return settingFindByName(ctx, hashIDSlatName)
 }
 
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"URLS/internal/utils/bsonext"
+	"context"
+	"time"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+const outboxCollName string = "outbox" + collSuffix
+
+var outboxColl *qmgo.Collection
+
+func initOutboxCollIndex(ctx context.Context) (err error) {
+	err = outboxColl.CreateOneIndex(ctx, options.IndexModel{Key: []string{"nextat"}})
+
+	return
+}
+
+// OutboxKind 寫入 link 後需要在其他服務執行的操作
+type OutboxKind string
+
+const (
+	OKQuota OutboxKind = "quota" // 更新使用者或工作區的使用量
+)
+
+// OutboxQuotaInfo 更新使用量時使用的資料
+type OutboxQuotaInfo struct {
+	UserIDHex       string `bson:"user"`
+	WorkspaceIDHex  string `bson:"workspace,omitempty"` // 不為空時更新工作區的使用量
+	NormalUsageDiff int64  `bson:"normaldiff"`
+	CustomUsageDiff int64  `bson:"customdiff"`
+}
+
+// OutboxInfo 與 link 在同一個 transaction 中寫入，commit 後才執行的操作
+//
+// 操作失敗時會被重試，同一個操作可能被執行多次，因此執行的方式必須是冪等的
+type OutboxInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Kind  OutboxKind       `bson:"kind"`
+	Quota *OutboxQuotaInfo `bson:"quota,omitempty"` // OKQuota 要更新的使用量
+
+	Attempts int       `bson:"attempts"`          // 已嘗試執行的次數
+	NextAt   time.Time `bson:"nextat"`            // 可以被執行的時間
+	LastErr  string    `bson:"lasterr,omitempty"` // 最後一次執行失敗的原因
+}
+
+func newOutbox(kind OutboxKind) *OutboxInfo {
+	op := &OutboxInfo{
+		Kind:   kind,
+		NextAt: time.Now(),
+	}
+	op.Id = primitive.NewObjectID()
+	return op
+}
+
+// NewQuotaOutbox 更新使用量的操作
+func NewQuotaOutbox(quota *OutboxQuotaInfo) *OutboxInfo {
+	op := newOutbox(OKQuota)
+	op.Quota = quota
+	return op
+}
+
+// OutboxAdd 新增要執行的操作，需要與 link 的修改使用同一個 transaction
+func OutboxAdd(ctx context.Context, ops ...*OutboxInfo) (err error) {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	_, err = outboxColl.InsertMany(ctx, ops)
+	if err != nil {
+		logger.Error("insert outbox failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// outboxClaimUpdate 取得操作時的更新，lease 內不會被其他 service 重複取得
+func outboxClaimUpdate(lease time.Duration) bson.M {
+	return bson.M{
+		"$set": bson.M{"nextat": time.Now().Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+}
+
+// OutboxClaim 取得一個可以執行的操作，並在 lease 內避免被其他 service 重複執行
+func OutboxClaim(ctx context.Context, lease time.Duration) (op *OutboxInfo, exist bool, err error) {
+	op = new(OutboxInfo)
+	err = outboxColl.Find(ctx, bson.M{"nextat": bson.M{"$lte": time.Now()}}).
+		Sort("nextat").
+		Apply(qmgo.Change{
+			Update:    outboxClaimUpdate(lease),
+			ReturnNew: true,
+		}, op)
+	if err != nil {
+		op = nil
+		if qmgo.IsErrNoDocuments(err) {
+			err = nil
+			return
+		}
+		logger.Error("claim outbox failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	exist = true
+	return
+}
+
+// Claim 取得指定的操作，已被其他 service 取得或已執行完成時回傳 false
+func (o *OutboxInfo) Claim(ctx context.Context, lease time.Duration) (claimed bool, err error) {
+	err = outboxColl.Find(ctx, bson.M{"_id": o.Id, "nextat": bson.M{"$lte": time.Now()}}).
+		Apply(qmgo.Change{
+			Update:    outboxClaimUpdate(lease),
+			ReturnNew: true,
+		}, o)
+	if err != nil {
+		if qmgo.IsErrNoDocuments(err) {
+			return false, nil
+		}
+		logger.Error("claim outbox by id failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return true, nil
+}
+
+// Done 移除已執行完成的操作
+func (o *OutboxInfo) Done(ctx context.Context) (err error) {
+	err = outboxColl.Remove(ctx, bsonext.ID(o.Id))
+	if err != nil && !qmgo.IsErrNoDocuments(err) {
+		logger.Error("remove outbox failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
+
+// Retry 記錄執行失敗的原因，並在 delay 後重新執行
+func (o *OutboxInfo) Retry(ctx context.Context, lastErr string, delay time.Duration) (err error) {
+	nextAt := time.Now().Add(delay)
+	err = outboxColl.UpdateOne(ctx, bsonext.ID(o.Id),
+		bsonext.Set(bson.M{"nextat": nextAt, "lasterr": lastErr}))
+	if err != nil {
+		logger.Error("update outbox retry failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	o.NextAt = nextAt
+	o.LastErr = lastErr
+	return
+}
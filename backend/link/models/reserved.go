@@ -0,0 +1,169 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	officialOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const reservedWordCollName string = "reservedwords" + collSuffix
+
+var reservedWordColl *qmgo.Collection
+
+func initReservedWordCollIndex(ctx context.Context) (err error) {
+	uniqueOpts := officialOpts.Index()
+	uniqueOpts.SetUnique(true)
+
+	err = reservedWordColl.CreateOneIndex(ctx,
+		options.IndexModel{Key: []string{"word"}, IndexOptions: uniqueOpts})
+
+	return
+}
+
+// ReservedWordInfo 不能被使用為客製化短網址的字詞
+type ReservedWordInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Word    string             `bson:"word"`              // 小寫的字詞
+	Builtin bool               `bson:"builtin"`           // 是否為服務路由使用的字詞，無法被移除
+	Creator primitive.ObjectID `bson:"creator,omitempty"` // 加入的管理員，由設定檔加入時為空
+}
+
+// ReservedWordNormalize 轉換為比對保留字時使用的格式
+func ReservedWordNormalize(word string) string {
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+// ReservedWordSeed 將服務路由與設定檔中的保留字加入資料庫
+//
+// 服務路由的字詞會被標記為 builtin，設定檔中的字詞則和執行期間加入的字詞相同
+func ReservedWordSeed(ctx context.Context, builtinWords, cfgWords []string) (err error) {
+	upsertOpts := options.UpdateOptions{UpdateOptions: officialOpts.Update().SetUpsert(true)}
+	seed := func(words []string, builtin bool) error {
+		for _, word := range words {
+			word = ReservedWordNormalize(word)
+			if word == "" {
+				continue
+			}
+
+			nowTime := time.Now()
+			onInsert := bson.M{"createAt": nowTime, "updateAt": nowTime}
+			update := bson.M{"$setOnInsert": onInsert}
+			if builtin {
+				update["$set"] = bson.M{"builtin": true}
+			} else {
+				onInsert["builtin"] = false
+			}
+
+			err := reservedWordColl.UpdateOne(ctx, bson.M{"word": word}, update, upsertOpts)
+			if err != nil {
+				logger.Error("seed reserved word failed", zap.String("word", word), zap.Error(err))
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err = seed(builtinWords, true); err != nil {
+		return
+	}
+	return seed(cfgWords, false)
+}
+
+// ReservedWordAdd 新增保留字，已存在的字詞會被略過
+func ReservedWordAdd(ctx context.Context, words []string, creator primitive.ObjectID) (err error) {
+	for _, word := range words {
+		_, err = reservedWordColl.InsertOne(ctx, &ReservedWordInfo{
+			Word:    ReservedWordNormalize(word),
+			Creator: creator,
+		})
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				err = nil
+				continue
+			}
+			logger.Error("insert reserved word failed", zap.Error(err))
+			err = common.GRPCErrInternal
+			return
+		}
+	}
+
+	return nil
+}
+
+// ReservedWordRemove 移除非 builtin 的保留字
+func ReservedWordRemove(ctx context.Context, word string) (exist bool, err error) {
+	err = reservedWordColl.Remove(ctx, bson.M{"word": ReservedWordNormalize(word), "builtin": false})
+	if err != nil {
+		if qmgo.IsErrNoDocuments(err) {
+			return false, nil
+		}
+		logger.Error("remove reserved word failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return true, nil
+}
+
+// ReservedWordList 回傳所有保留字
+func ReservedWordList(ctx context.Context) (list []*ReservedWordInfo, err error) {
+	err = reservedWordColl.Find(ctx, bson.M{}).Sort("word").All(&list)
+	if err != nil {
+		logger.Error("list reserved word failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// IsReservedWord 字詞是否為保留字
+func IsReservedWord(ctx context.Context, word string) (reserved bool, err error) {
+	num, err := reservedWordColl.Find(ctx, bson.M{"word": ReservedWordNormalize(word)}).Count()
+	if err != nil {
+		logger.Error("find reserved word failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return num > 0, nil
+}
+
+// ReservedWordsExist 回傳 words 中為保留字的字詞 (正規化前的形式)
+func ReservedWordsExist(ctx context.Context, words []string) (exist map[string]struct{}, err error) {
+	normalized := make([]string, 0, len(words))
+	for _, word := range words {
+		normalized = append(normalized, ReservedWordNormalize(word))
+	}
+
+	var reserved []string
+	err = reservedWordColl.Find(ctx, bson.M{"word": bson.M{"$in": normalized}}).Distinct("word", &reserved)
+	if err != nil {
+		logger.Error("distinct reserved word failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	reservedSet := make(map[string]struct{}, len(reserved))
+	for _, word := range reserved {
+		reservedSet[word] = struct{}{}
+	}
+	exist = make(map[string]struct{})
+	for i, word := range words {
+		if _, ok := reservedSet[normalized[i]]; ok {
+			exist[word] = struct{}{}
+		}
+	}
+	return exist, nil
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"URLS/internal/utils/bsonext"
+	"context"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	officialOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const utmTemplateCollName string = "utmtemplates" + collSuffix
+
+var utmTemplateColl *qmgo.Collection
+
+func initUTMTemplateCollIndex(ctx context.Context) (err error) {
+	uniqueOpts := officialOpts.Index()
+	uniqueOpts.SetUnique(true)
+
+	err = utmTemplateColl.CreateOneIndex(ctx,
+		options.IndexModel{Key: []string{"owner", "name"}, IndexOptions: uniqueOpts})
+
+	return
+}
+
+// UTMTemplateInfo 有名稱的 UTM 範本，同一個擁有者的範本名稱不能重複
+type UTMTemplateInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Owner     primitive.ObjectID `bson:"owner"`     // 個人的範本為使用者 id，工作區的範本為工作區 id
+	Workspace bool               `bson:"workspace"` // 是否為工作區的範本
+	Name      string             `bson:"name"`      // 名稱
+	UTMInfo   UTMInfo            `bson:"utm"`
+}
+
+// utmTemplateWriteErrConvert 將寫入時的錯誤轉換為 gRPC 錯誤
+func utmTemplateWriteErrConvert(err error, logMsg string) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return status.Error(codes.AlreadyExists, "utm template with the same name already exists")
+	}
+	logger.Error(logMsg, zap.Error(err))
+	return common.GRPCErrInternal
+}
+
+// UTMTemplateCreate 建立 UTM 範本
+func UTMTemplateCreate(ctx context.Context, owner primitive.ObjectID, isWorkspace bool, name string, utm *UTMInfo) (
+	*UTMTemplateInfo, error) {
+	template := &UTMTemplateInfo{
+		Owner:     owner,
+		Workspace: isWorkspace,
+		Name:      name,
+		UTMInfo:   *utm,
+	}
+	_, err := utmTemplateColl.InsertOne(ctx, template)
+	if err != nil {
+		return nil, utmTemplateWriteErrConvert(err, "insert utm template failed")
+	}
+
+	return template, nil
+}
+
+// UTMTemplateFindByID 根據 id 尋找 UTM 範本
+func UTMTemplateFindByID(ctx context.Context, id primitive.ObjectID) (template *UTMTemplateInfo, exist bool, err error) {
+	template = new(UTMTemplateInfo)
+	err = utmTemplateColl.Find(ctx, bsonext.ID(id)).One(template)
+	if err != nil {
+		template = nil
+		if qmgo.IsErrNoDocuments(err) {
+			return nil, false, nil
+		}
+		logger.Error("find utm template by id failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return template, true, nil
+}
+
+// UTMTemplateListByOwner 回傳使用者或工作區的所有 UTM 範本
+func UTMTemplateListByOwner(ctx context.Context, owner primitive.ObjectID) (list []*UTMTemplateInfo, err error) {
+	err = utmTemplateColl.Find(ctx, bson.M{"owner": owner}).Sort("name").All(&list)
+	if err != nil {
+		logger.Error("list utm template failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// UTMTemplateCountByOwner 回傳使用者或工作區的 UTM 範本數量
+func UTMTemplateCountByOwner(ctx context.Context, owner primitive.ObjectID) (num int64, err error) {
+	num, err = utmTemplateColl.Find(ctx, bson.M{"owner": owner}).Count()
+	if err != nil {
+		logger.Error("count utm template failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+type UTMTemplatePatchInfo struct {
+	PName    bool
+	Name     string
+	PUTMInfo bool
+	UTMInfo  *UTMInfo
+}
+
+func (t *UTMTemplateInfo) Patch(ctx context.Context, pInfo *UTMTemplatePatchInfo) (err error) {
+	updateCol := bson.M{}
+	if pInfo.PName {
+		updateCol["name"] = pInfo.Name
+	}
+	if pInfo.PUTMInfo {
+		updateCol["utm"] = pInfo.UTMInfo
+	}
+
+	err = utmTemplateColl.UpdateOne(ctx, bsonext.ID(t.Id), bsonext.Set(updateCol))
+	if err != nil {
+		return utmTemplateWriteErrConvert(err, "patch utm template failed")
+	}
+
+	return nil
+}
+
+func (t *UTMTemplateInfo) Delete(ctx context.Context) (err error) {
+	err = utmTemplateColl.RemoveId(ctx, t.Id)
+	if err != nil && !qmgo.IsErrNoDocuments(err) {
+		logger.Error("delete utm template failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
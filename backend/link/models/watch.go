@@ -0,0 +1,200 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"URLS/internal/utils/bsonext"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	officialOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	// linkWatchTokenName link change stream 的 resume token 在 other collection 中的名稱
+	linkWatchTokenName string = "linkwatchtoken"
+	// linkWatchLeaseName 監聽 link change stream 的 lease 在 other collection 中的名稱
+	linkWatchLeaseName string = "linkwatchlease"
+)
+
+// linkRoutingFields 會影響 redirector 導向結果的欄位
+var linkRoutingFields = []string{"type", "deleted", "short", "host", "dest", "querys", "passthrough", "redirectcode", "creator"}
+
+// ErrLinkWatchTokenLost 保存的 resume token 已不在 oplog 中，無法從上次處理到的位置繼續
+var ErrLinkWatchTokenLost = errors.New("link watch resume token is lost")
+
+// linkWatchTokenInfo 保存 change stream 處理到的位置
+type linkWatchTokenInfo struct {
+	Name      string   `bson:"name"`
+	Value     bson.Raw `bson:"value"`
+	Reconcile bool     `bson:"reconcile,omitempty"` // 有變更可能被遺漏，繼續處理前需要先重建 redirector 的資料
+}
+
+// LinkWatchTokenGet 回傳上次處理到的 resume token，不存在時回傳 nil
+//
+// reconcile 為 true 時，需要先重建 redirector 的資料才能從 token 繼續
+func LinkWatchTokenGet(ctx context.Context) (token bson.Raw, reconcile bool, err error) {
+	res := linkWatchTokenInfo{}
+	err = otherColl.Find(ctx, bsonext.Name(linkWatchTokenName)).One(&res)
+	if err != nil {
+		if qmgo.IsErrNoDocuments(err) {
+			return nil, false, nil
+		}
+		logger.Error("find link watch token failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return res.Value, res.Reconcile, nil
+}
+
+// LinkWatchTokenSave 保存處理到的 resume token，reconcile 見 LinkWatchTokenGet
+func LinkWatchTokenSave(ctx context.Context, token bson.Raw, reconcile bool) (err error) {
+	_, err = otherColl.Upsert(ctx, bsonext.Name(linkWatchTokenName),
+		&linkWatchTokenInfo{Name: linkWatchTokenName, Value: token, Reconcile: reconcile})
+	if err != nil {
+		logger.Error("save link watch token failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// LinkWatchTokenReset 移除保存的 resume token，下次從目前的時間開始處理
+func LinkWatchTokenReset(ctx context.Context) (err error) {
+	err = otherColl.Remove(ctx, bsonext.Name(linkWatchTokenName))
+	if err != nil && !qmgo.IsErrNoDocuments(err) {
+		logger.Error("remove link watch token failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
+
+// linkWatchLeaseInfo 目前負責監聽 link change stream 的 service
+type linkWatchLeaseInfo struct {
+	Name     string    `bson:"name"`
+	Holder   string    `bson:"holder"`
+	ExpireAt time.Time `bson:"expireat"`
+}
+
+// LinkWatchLeaseAcquire 取得或延長監聽 link change stream 的 lease，lease 被其他 service 持有且尚未過期時回傳 false
+//
+// 同時只有一個 service 會監聽 change stream，持有者停止延長後，其他 service 在 lease 過期後才能取得
+func LinkWatchLeaseAcquire(ctx context.Context, holder string, lease time.Duration) (acquired bool, err error) {
+	now := time.Now()
+	filter := bson.M{
+		"name": linkWatchLeaseName,
+		"$or": []bson.M{
+			{"holder": holder},
+			{"expireat": bson.M{"$lte": now}},
+		},
+	}
+	res := linkWatchLeaseInfo{}
+	err = otherColl.Find(ctx, filter).Apply(qmgo.Change{
+		Update:    bson.M{"$set": bson.M{"holder": holder, "expireat": now.Add(lease)}},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &res)
+	if err != nil {
+		// lease 被其他 service 持有時 filter 不會符合，upsert 會因為 name 重複而失敗
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		logger.Error("acquire link watch lease failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return true, nil
+}
+
+// linkWatchErrConvert 將 change stream 的錯誤轉換為 ErrLinkWatchTokenLost 或 gRPC 錯誤
+func linkWatchErrConvert(err error, logMsg string) error {
+	const (
+		codeChangeStreamFatalError  = 280
+		codeChangeStreamHistoryLost = 286
+	)
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) &&
+		(serverErr.HasErrorCode(codeChangeStreamHistoryLost) || serverErr.HasErrorCode(codeChangeStreamFatalError)) {
+		return ErrLinkWatchTokenLost
+	}
+	logger.Error(logMsg, zap.Error(err))
+	return common.GRPCErrInternal
+}
+
+// LinkChangeStream 新增 link 或導向相關欄位被修改的事件
+type LinkChangeStream struct {
+	cs *mongo.ChangeStream
+}
+
+// LinkWatch 開始監聽 link 的變更，token 為空時從目前的時間開始
+//
+// mongo 需要是 replica set 才能使用 change stream
+func LinkWatch(ctx context.Context, token bson.Raw) (stream *LinkChangeStream, err error) {
+	match := make([]bson.M, 0, len(linkRoutingFields)+2)
+	match = append(match, bson.M{"operationType": bsonext.In([]string{"insert", "replace"})})
+	for _, field := range linkRoutingFields {
+		match = append(match, bson.M{
+			"operationType": "update",
+			"updateDescription.updatedFields." + field: bson.M{"$exists": true},
+		})
+	}
+	match = append(match, bson.M{
+		"operationType":                   "update",
+		"updateDescription.removedFields": bsonext.In(linkRoutingFields),
+	})
+
+	csOpts := officialOpts.ChangeStream().SetFullDocument(officialOpts.UpdateLookup)
+	if len(token) > 0 {
+		csOpts.SetResumeAfter(token)
+	}
+	cs, err := linkColl.Watch(ctx, []bson.M{bsonext.Match(bson.M{"$or": match})},
+		&options.ChangeStreamOptions{ChangeStreamOptions: csOpts})
+	if err != nil {
+		return nil, linkWatchErrConvert(err, "watch link failed")
+	}
+
+	return &LinkChangeStream{cs: cs}, nil
+}
+
+// Next 等待並回傳下一個被變更的 link 目前的資料
+//
+// 事件發生後 link 被從資料庫移除時會跳過該事件
+func (s *LinkChangeStream) Next(ctx context.Context) (link *LinkInfo, err error) {
+	for s.cs.Next(ctx) {
+		var event struct {
+			FullDocument *LinkInfo `bson:"fullDocument"`
+		}
+		if err = s.cs.Decode(&event); err != nil {
+			logger.Error("decode link change event failed", zap.Error(err))
+			return nil, common.GRPCErrInternal
+		}
+		if event.FullDocument != nil {
+			return event.FullDocument, nil
+		}
+	}
+
+	if err = s.cs.Err(); err == nil {
+		err = ctx.Err()
+	}
+	return nil, linkWatchErrConvert(err, "link change stream failed")
+}
+
+// Token 回傳最後一個被回傳的事件的 resume token
+func (s *LinkChangeStream) Token() bson.Raw {
+	return s.cs.ResumeToken()
+}
+
+func (s *LinkChangeStream) Close(ctx context.Context) {
+	_ = s.cs.Close(ctx)
+}
@@ -0,0 +1,147 @@
+package codegen
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/speps/go-hashids/v2"
+)
+
+const (
+	// DefaultAlphabet random 預設使用的字元 (base62)
+	DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	defaultHashIDsMinLength = 5
+	defaultRandomLength     = 7
+	maxLength               = 64
+)
+
+// Strategy 自動產生短網址的方式
+type Strategy string
+
+const (
+	StrategyHashIDs Strategy = "hashids" // 以計數器搭配 hashids 產生，產生的短網址不會重複
+	StrategyRandom  Strategy = "random"  // 隨機產生，可能與已存在的短網址重複
+)
+
+// StrategyFromString 將設定值轉換為 Strategy，無法辨識時回傳 false
+func StrategyFromString(s string) (Strategy, bool) {
+	switch Strategy(strings.ToLower(s)) {
+	case "", StrategyHashIDs:
+		return StrategyHashIDs, true
+	case StrategyRandom:
+		return StrategyRandom, true
+	default:
+		return "", false
+	}
+}
+
+// Generator 產生短網址
+type Generator interface {
+	Generate(ctx context.Context) (string, error)
+}
+
+// CounterFunc 回傳下一個計數器的值
+type CounterFunc func(ctx context.Context) ([]int64, error)
+
+// HashIDs 將計數器的值以 hashids 編碼為短網址
+type HashIDs struct {
+	counter CounterFunc
+	hash    *hashids.HashID
+}
+
+// NewHashIDs alphabet 為空時使用 hashids 的預設字元，minLength <= 0 時最短長度為 5
+func NewHashIDs(counter CounterFunc, salt, alphabet string, minLength int) (*HashIDs, error) {
+	if alphabet == "" {
+		alphabet = hashids.DefaultAlphabet
+	}
+	if err := alphabetCheck(alphabet); err != nil {
+		return nil, err
+	}
+	if minLength <= 0 {
+		minLength = defaultHashIDsMinLength
+	} else if minLength > maxLength {
+		return nil, fmt.Errorf("length can not be greater than %d", maxLength)
+	}
+
+	hd := hashids.NewData()
+	hd.Salt = salt
+	hd.Alphabet = alphabet
+	hd.MinLength = minLength
+	hash, err := hashids.NewWithData(hd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HashIDs{counter: counter, hash: hash}, nil
+}
+
+func (h *HashIDs) Generate(ctx context.Context) (string, error) {
+	val, err := h.counter(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return h.hash.EncodeInt64(val)
+}
+
+// Random 以 crypto/rand 從 alphabet 中隨機選出固定長度的短網址
+type Random struct {
+	alphabet []byte
+	length   int
+}
+
+// NewRandom alphabet 為空時使用 DefaultAlphabet，length <= 0 時長度為 7
+func NewRandom(alphabet string, length int) (*Random, error) {
+	if alphabet == "" {
+		alphabet = DefaultAlphabet
+	}
+	if err := alphabetCheck(alphabet); err != nil {
+		return nil, err
+	}
+	if length <= 0 {
+		length = defaultRandomLength
+	} else if length > maxLength {
+		return nil, fmt.Errorf("length can not be greater than %d", maxLength)
+	}
+
+	return &Random{alphabet: []byte(alphabet), length: length}, nil
+}
+
+func (r *Random) Generate(ctx context.Context) (string, error) {
+	max := big.NewInt(int64(len(r.alphabet)))
+	code := make([]byte, r.length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = r.alphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// alphabetCheck 字元只能是英文字母、數字、'-'、'_'，且不可重複
+func alphabetCheck(alphabet string) error {
+	var seen [128]bool
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("alphabet contains invalid character %q", c)
+		}
+		if seen[c] {
+			return fmt.Errorf("alphabet contains duplicate character %q", c)
+		}
+		seen[c] = true
+	}
+	if len(alphabet) < 2 {
+		return errors.New("alphabet must contain at least 2 characters")
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package codegen
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/speps/go-hashids/v2"
+)
+
+func TestHashIDsCompatible(t *testing.T) {
+	const salt = "test salt"
+	var counter int64
+	gen, err := NewHashIDs(func(ctx context.Context) ([]int64, error) {
+		counter++
+		return []int64{counter}, nil
+	}, salt, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 預設設定需要和原本的 hashids 設定產生相同的短網址
+	hd := hashids.NewData()
+	hd.Salt = salt
+	hd.MinLength = 5
+	old, err := hashids.NewWithData(hd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := int64(1); i <= 100; i++ {
+		got, err := gen.Generate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := old.EncodeInt64([]int64{i})
+		if got != want {
+			t.Fatalf("Generate() = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz"
+	gen, err := NewRandom(alphabet, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		code, err := gen.Generate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 10 {
+			t.Fatalf("len(%s) = %d, want 10", code, len(code))
+		}
+		for _, c := range code {
+			if !strings.ContainsRune(alphabet, c) {
+				t.Fatalf("code %s contains character %q not in alphabet", code, c)
+			}
+		}
+		seen[code] = struct{}{}
+	}
+	if len(seen) < 90 {
+		t.Errorf("too many duplicate codes: %d unique in 100", len(seen))
+	}
+}
+
+func TestAlphabetCheck(t *testing.T) {
+	for _, alphabet := range []string{"a", "aab", "ab c", "ab/", "短網址"} {
+		if _, err := NewRandom(alphabet, 0); err == nil {
+			t.Errorf("NewRandom(%q) err = nil, want error", alphabet)
+		}
+	}
+	if _, err := NewRandom("ab", 0); err != nil {
+		t.Errorf("NewRandom(ab) err = %s, want nil", err)
+	}
+}
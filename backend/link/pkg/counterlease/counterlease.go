@@ -0,0 +1,74 @@
+package counterlease
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const defaultBlockSize = 100
+
+// Block 從儲存端租用的一段計數器的值
+//
+// 區塊內的值為 [Start, End]，每個值搭配相同的 Upper 組成完整的計數器值
+type Block struct {
+	Start int64
+	End   int64
+	Upper []int64 // 計數器進位後的高位數值
+}
+
+// LeaseFunc 向儲存端租用 size 個連續的值
+type LeaseFunc func(ctx context.Context, size int64) (Block, error)
+
+var ErrEmptyBlock = errors.New("leased block is empty")
+
+// Allocator 在記憶體中分配租用到的計數器值，用完時才向儲存端租用下一個區塊
+//
+// 程式結束時尚未分配的值不會被歸還，產生的值會有間隔但不會重複
+type Allocator struct {
+	lease     LeaseFunc
+	blockSize int64
+
+	mu    sync.Mutex
+	next  int64
+	block Block
+	valid bool
+}
+
+// New blockSize <= 0 時每次租用 100 個值
+func New(lease LeaseFunc, blockSize int64) *Allocator {
+	if blockSize <= 0 {
+		blockSize = defaultBlockSize
+	}
+
+	return &Allocator{
+		lease:     lease,
+		blockSize: blockSize,
+	}
+}
+
+// Next 回傳下一個計數器的值，格式為 [value, upper...]
+func (a *Allocator) Next(ctx context.Context) ([]int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.valid || a.next > a.block.End {
+		block, err := a.lease(ctx, a.blockSize)
+		if err != nil {
+			return nil, err
+		}
+		if block.Start > block.End {
+			return nil, ErrEmptyBlock
+		}
+		a.block = block
+		a.next = block.Start
+		a.valid = true
+	}
+
+	val := make([]int64, 0, len(a.block.Upper)+1)
+	val = append(val, a.next)
+	val = append(val, a.block.Upper...)
+	a.next++
+
+	return val, nil
+}
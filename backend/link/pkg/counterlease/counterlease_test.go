@@ -0,0 +1,97 @@
+package counterlease
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore 模擬資料庫中的單一計數器文件，每次操作都需要等待 latency
+type memStore struct {
+	mu      sync.Mutex
+	value   int64
+	latency time.Duration
+}
+
+func (s *memStore) lease(ctx context.Context, size int64) (Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	time.Sleep(s.latency)
+	s.value += size
+	return Block{Start: s.value - size + 1, End: s.value, Upper: []int64{7}}, nil
+}
+
+func TestAllocatorUnique(t *testing.T) {
+	store := &memStore{}
+	a := New(store.lease, 10)
+
+	const workers, perWorker = 8, 100
+	var mu sync.Mutex
+	seen := make(map[string]struct{}, workers*perWorker)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				val, err := a.Next(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				key := fmt.Sprint(val)
+				mu.Lock()
+				if _, exist := seen[key]; exist {
+					t.Errorf("duplicate value %s", key)
+				}
+				seen[key] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != workers*perWorker {
+		t.Errorf("got %d values, want %d", len(seen), workers*perWorker)
+	}
+	if want := int64(workers * perWorker); store.value != want {
+		t.Errorf("store value = %d, want %d", store.value, want)
+	}
+}
+
+func TestAllocatorFormat(t *testing.T) {
+	a := New((&memStore{}).lease, 2)
+	for want := int64(1); want <= 3; want++ {
+		val, err := a.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(val) != 2 || val[0] != want || val[1] != 7 {
+			t.Fatalf("Next() = %v, want [%d 7]", val, want)
+		}
+	}
+}
+
+// benchmarkAllocator 以 1ms 的記憶體模擬延遲比較不同租用大小下 Allocator 的分配速度
+//
+// 只測量 Allocator 本身，實際向 mongo 租用與建立短網址的速度見 link/models 的 BenchmarkLinkCreate
+func benchmarkAllocator(b *testing.B, blockSize int64) {
+	a := New((&memStore{latency: time.Millisecond}).lease, blockSize)
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := a.Next(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkAllocatorBlock1(b *testing.B)    { benchmarkAllocator(b, 1) }
+func BenchmarkAllocatorBlock100(b *testing.B)  { benchmarkAllocator(b, 100) }
+func BenchmarkAllocatorBlock1000(b *testing.B) { benchmarkAllocator(b, 1000) }
@@ -0,0 +1,285 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	defaultRatePerSec         = 10
+	defaultPerHostConcurrency = 2
+	defaultTimeout            = 10 * time.Second
+
+	// maxRedirects 最多跟隨的重新導向次數
+	maxRedirects = 10
+	// getBodyReadLimit 使用 GET 檢查時最多讀取的 body 大小
+	getBodyReadLimit = 64 * 1024
+)
+
+const userAgent = "URLS-LinkChecker/1.0"
+
+var (
+	ErrTooManyRedirects = errors.New("stopped after too many redirects")
+	ErrNotHTML          = errors.New("content is not html")
+)
+
+// Options 建立 Checker 時的設定
+type Options struct {
+	RatePerSec         int           // 每秒最多發出的請求數
+	PerHostConcurrency int           // 同一個 host 同時進行的請求數
+	Timeout            time.Duration // 單次請求的逾時時間
+
+	// RedirectCheck 跟隨重新導向前的檢查，回傳錯誤時會停止導向
+	RedirectCheck func(u *url.URL) error
+	// DialCheck 連線前檢查網域解析後的 IP，回傳錯誤時不會連線，包含重新導向後的連線
+	DialCheck func(ip net.IP) error
+}
+
+// Result 檢查結果
+type Result struct {
+	StatusCode int // HTTP 狀態碼，無法連線時為 0
+	Latency    time.Duration
+	Err        error
+}
+
+// Broken 目的地是否無法正常使用
+func (r Result) Broken() bool {
+	return r.Err != nil || r.StatusCode >= http.StatusBadRequest
+}
+
+// Checker 以限制速率與同 host 併發數的方式檢查網址是否可用
+type Checker struct {
+	client  *http.Client
+	perHost int
+	limiter *time.Ticker
+
+	mu      sync.Mutex
+	hostSem map[string]*hostSlot
+}
+
+// hostSlot 同一個 host 的併發限制，users 為持有與等待的請求數，為 0 時會從 hostSem 移除
+type hostSlot struct {
+	sem   chan struct{}
+	users int
+}
+
+func New(opts Options) *Checker {
+	if opts.RatePerSec <= 0 {
+		opts.RatePerSec = defaultRatePerSec
+	}
+	if opts.PerHostConcurrency <= 0 {
+		opts.PerHostConcurrency = defaultPerHostConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if opts.DialCheck != nil {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return errors.New("dial address is not an ip: " + address)
+			}
+			return opts.DialCheck(ip)
+		}
+	}
+
+	client := &http.Client{
+		// 不使用環境變數的 proxy，否則 DialCheck 只會檢查到 proxy 的位址
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: opts.Timeout,
+			MaxIdleConnsPerHost: opts.PerHostConcurrency,
+		},
+		Timeout: opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return ErrTooManyRedirects
+			}
+			if opts.RedirectCheck != nil {
+				return opts.RedirectCheck(req.URL)
+			}
+			return nil
+		},
+	}
+
+	return &Checker{
+		client:  client,
+		perHost: opts.PerHostConcurrency,
+		limiter: time.NewTicker(time.Second / time.Duration(opts.RatePerSec)),
+		hostSem: make(map[string]*hostSlot),
+	}
+}
+
+// Close 停止速率限制器
+func (c *Checker) Close() {
+	c.limiter.Stop()
+}
+
+func (c *Checker) hostAcquire(ctx context.Context, host string) (release func(), err error) {
+	c.mu.Lock()
+	slot, exist := c.hostSem[host]
+	if !exist {
+		slot = &hostSlot{sem: make(chan struct{}, c.perHost)}
+		c.hostSem[host] = slot
+	}
+	slot.users++
+	c.mu.Unlock()
+
+	select {
+	case slot.sem <- struct{}{}:
+	case <-ctx.Done():
+		c.hostRelease(host, slot)
+		return nil, ctx.Err()
+	}
+
+	return func() {
+		<-slot.sem
+		c.hostRelease(host, slot)
+	}, nil
+}
+
+// hostRelease 減少 slot 的使用數，沒有請求使用時移除
+func (c *Checker) hostRelease(host string, slot *hostSlot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slot.users--
+	if slot.users == 0 {
+		delete(c.hostSem, host)
+	}
+}
+
+func (c *Checker) wait(ctx context.Context) error {
+	select {
+	case <-c.limiter.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Checker) do(ctx context.Context, method, rawURL string) (statusCode int, latency time.Duration, err error) {
+	if err = c.wait(ctx); err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, http.NoBody)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	startTime := time.Now()
+	resp, err := c.client.Do(req)
+	latency = time.Since(startTime)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if method == http.MethodGet {
+		_, _ = io.CopyN(io.Discard, resp.Body, getBodyReadLimit)
+	}
+
+	return resp.StatusCode, latency, nil
+}
+
+// Check 檢查網址是否可用
+//
+// 先使用 HEAD 請求，失敗或回傳錯誤狀態碼時再改用 GET，因為部分網站不支援 HEAD
+func (c *Checker) Check(ctx context.Context, rawURL string) (res Result) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		res.Err = err
+		return
+	}
+
+	release, err := c.hostAcquire(ctx, u.Host)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	defer release()
+
+	res.StatusCode, res.Latency, res.Err = c.do(ctx, http.MethodHead, rawURL)
+	if res.Broken() {
+		res.StatusCode, res.Latency, res.Err = c.do(ctx, http.MethodGet, rawURL)
+	}
+
+	return res
+}
+
+// Title 讀取網頁的 <title>，只會讀取 body 的前 64 KiB
+func (c *Checker) Title(ctx context.Context, rawURL string) (title string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+
+	release, err := c.hostAcquire(ctx, u.Host)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	if err = c.wait(ctx); err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		err = errors.New("unexpected status " + resp.Status)
+		return
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		err = ErrNotHTML
+		return
+	}
+
+	return htmlTitle(io.LimitReader(resp.Body, getBodyReadLimit)), nil
+}
+
+// htmlTitle 回傳第一個 <title> 的文字內容，找不到時回傳空字串
+func htmlTitle(r io.Reader) string {
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if string(name) != "title" {
+				continue
+			}
+			if z.Next() == html.TextToken {
+				return strings.TrimSpace(string(z.Text()))
+			}
+			return ""
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHostAcquirePrune(t *testing.T) {
+	c := New(Options{PerHostConcurrency: 1})
+	defer c.Close()
+
+	release, err := c.hostAcquire(context.Background(), "a.com")
+	if err != nil {
+		t.Fatalf("hostAcquire failed, err=%s", err)
+	}
+
+	// 超過併發數的請求在 ctx 結束時放棄等待
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = c.hostAcquire(ctx, "a.com"); err == nil {
+		t.Fatal("hostAcquire over concurrency succeeded, want error")
+	}
+	if n := len(c.hostSem); n != 1 {
+		t.Errorf("len(hostSem) = %d, want 1", n)
+	}
+
+	release()
+	if n := len(c.hostSem); n != 0 {
+		t.Errorf("len(hostSem) after release = %d, want 0", n)
+	}
+}
+
+func TestDialCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	errDenied := errors.New("denied")
+	c := New(Options{
+		RatePerSec: 1000,
+		DialCheck: func(ip net.IP) error {
+			if ip.IsLoopback() {
+				return errDenied
+			}
+			return nil
+		},
+	})
+	defer c.Close()
+
+	res := c.Check(context.Background(), srv.URL)
+	if !errors.Is(res.Err, errDenied) {
+		t.Errorf("Check(%s).Err = %v, want %v", srv.URL, res.Err, errDenied)
+	}
+}
@@ -0,0 +1,85 @@
+// Package passthrough 將造訪短網址時帶有的 query 合併到目的地網址
+package passthrough
+
+import (
+	"net/url"
+)
+
+// Conflict 造訪時的 query 與目的地網址中已有的參數同名時的處理方式
+type Conflict int32
+
+const (
+	_                Conflict = iota
+	ConflictIncoming          // 使用造訪時的值
+	ConflictStored            // 使用目的地網址中的值
+	ConflictDrop              // 兩者都不使用
+)
+
+// ConflictFromInteger 將整數轉換為 Conflict，無法辨識時回傳 false
+func ConflictFromInteger[T ~int32 | ~int](v T) (Conflict, bool) {
+	switch conv := Conflict(v); conv {
+	case ConflictIncoming, ConflictStored, ConflictDrop:
+		return conv, true
+	default:
+		return 0, false
+	}
+}
+
+// Policy 合併 query 的規則
+type Policy struct {
+	Conflict Conflict
+	Allow    []string // 允許傳遞的參數名稱，為空時允許所有參數
+}
+
+func (p *Policy) allowed(name string) bool {
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, a := range p.Allow {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 依照規則將 incoming 合併到 dest 的 query 中
+//
+// 沒有需要合併的參數或 dest 無法解析時回傳原本的 dest
+func (p *Policy) Apply(dest string, incoming url.Values) string {
+	if len(incoming) == 0 {
+		return dest
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+
+	stored := u.Query()
+	changed := false
+	for name, vals := range incoming {
+		if !p.allowed(name) {
+			continue
+		}
+		if _, exist := stored[name]; !exist {
+			stored[name] = vals
+			changed = true
+			continue
+		}
+
+		switch p.Conflict {
+		case ConflictIncoming:
+			stored[name] = vals
+			changed = true
+		case ConflictDrop:
+			delete(stored, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return dest
+	}
+
+	u.RawQuery = stored.Encode()
+	return u.String()
+}
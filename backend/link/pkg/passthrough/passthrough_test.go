@@ -0,0 +1,47 @@
+package passthrough
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		dest     string
+		incoming string
+		want     string
+	}{
+		{"no incoming", Policy{Conflict: ConflictIncoming}, "https://a.com/p?b=2&a=1", "", "https://a.com/p?b=2&a=1"},
+		{"append", Policy{Conflict: ConflictIncoming}, "https://a.com/p", "ref=news", "https://a.com/p?ref=news"},
+		{"incoming wins", Policy{Conflict: ConflictIncoming}, "https://a.com/?utm_source=x", "utm_source=y&ref=n", "https://a.com/?ref=n&utm_source=y"},
+		{"stored wins", Policy{Conflict: ConflictStored}, "https://a.com/?utm_source=x", "utm_source=y&ref=n", "https://a.com/?ref=n&utm_source=x"},
+		{"stored wins unchanged", Policy{Conflict: ConflictStored}, "https://a.com/?utm_source=x", "utm_source=y", "https://a.com/?utm_source=x"},
+		{"drop", Policy{Conflict: ConflictDrop}, "https://a.com/?utm_source=x&a=1", "utm_source=y", "https://a.com/?a=1"},
+		{"allowlist", Policy{Conflict: ConflictIncoming, Allow: []string{"ref"}}, "https://a.com/", "ref=n&secret=1", "https://a.com/?ref=n"},
+		{"allowlist none", Policy{Conflict: ConflictIncoming, Allow: []string{"ref"}}, "https://a.com/", "secret=1", "https://a.com/"},
+		{"multi value", Policy{Conflict: ConflictIncoming}, "https://a.com/#top", "a=1&a=2", "https://a.com/?a=1&a=2#top"},
+	}
+
+	for _, tt := range tests {
+		incoming, err := url.ParseQuery(tt.incoming)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tt.policy.Apply(tt.dest, incoming); got != tt.want {
+			t.Errorf("%s: Apply() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConflictFromInteger(t *testing.T) {
+	for _, v := range []int32{0, 4, -1} {
+		if _, ok := ConflictFromInteger(v); ok {
+			t.Errorf("ConflictFromInteger(%d) should fail", v)
+		}
+	}
+	if c, ok := ConflictFromInteger(int32(3)); !ok || c != ConflictDrop {
+		t.Errorf("ConflictFromInteger(3) = %v, %v", c, ok)
+	}
+}
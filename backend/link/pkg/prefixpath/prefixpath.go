@@ -0,0 +1,28 @@
+// Package prefixpath 處理前綴短網址的路徑，短網址只比對第一段路徑，其餘的路徑會被加到目的地網址後面
+package prefixpath
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Split 將造訪的路徑分為第一段與其餘的路徑，路徑只有一段時回傳 false
+func Split(path string) (first, rest string, ok bool) {
+	i := strings.IndexByte(path, '/')
+	if i <= 0 {
+		return path, "", false
+	}
+	return path[:i], path[i+1:], true
+}
+
+// Append 將 rest 加到目的地網址的路徑後面，目的地網址無法解析時回傳原本的網址
+func Append(dest, rest string) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + rest
+	u.RawPath = ""
+	return u.String()
+}
@@ -0,0 +1,47 @@
+package prefixpath
+
+import "testing"
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		path        string
+		first, rest string
+		ok          bool
+	}{
+		{"docs", "docs", "", false},
+		{"docs/getting-started", "docs", "getting-started", true},
+		{"docs/a/b", "docs", "a/b", true},
+		{"docs/", "docs", "", true},
+		{"/docs", "/docs", "", false},
+	}
+
+	for _, tt := range tests {
+		first, rest, ok := Split(tt.path)
+		if first != tt.first || rest != tt.rest || ok != tt.ok {
+			t.Errorf("Split(%q) = (%q, %q, %t), want (%q, %q, %t)",
+				tt.path, first, rest, ok, tt.first, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	tests := []struct {
+		dest, rest string
+		want       string
+	}{
+		{"https://docs.example.com", "getting-started", "https://docs.example.com/getting-started"},
+		{"https://docs.example.com/", "getting-started", "https://docs.example.com/getting-started"},
+		{"https://a.com/v1/", "x/y", "https://a.com/v1/x/y"},
+		{"https://a.com/v1?lang=en#top", "x", "https://a.com/v1/x?lang=en#top"},
+		{"https://a.com/p", "", "https://a.com/p/"},
+		{"https://a.com/p", "a b", "https://a.com/p/a%20b"},
+		{"https://a.com/%7Euser", "x", "https://a.com/~user/x"},
+		{"://bad", "x", "://bad"},
+	}
+
+	for _, tt := range tests {
+		if got := Append(tt.dest, tt.rest); got != tt.want {
+			t.Errorf("Append(%q, %q) = %q, want %q", tt.dest, tt.rest, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package shortcode
+
+// confusables 外觀與 Latin 字母相同的 Cyrillic、Greek 字元
+//
+// 節錄自 Unicode confusables.txt 中對應到單一 Latin 字母的項目
+var confusables = map[rune]rune{
+	// Cyrillic 小寫
+	'а': 'a', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'ӏ': 'l',
+	'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'ԝ': 'w', 'х': 'x', 'у': 'y', 'ԍ': 'g',
+	'ь': 'b', 'ԃ': 'd', 'ҽ': 'e',
+
+	// Cyrillic 大寫
+	'А': 'A', 'В': 'B', 'С': 'C', 'Е': 'E', 'Н': 'H', 'І': 'I', 'Ј': 'J', 'К': 'K',
+	'М': 'M', 'О': 'O', 'Р': 'P', 'Ѕ': 'S', 'Т': 'T', 'Х': 'X', 'У': 'Y', 'Ԛ': 'Q',
+	'Ԝ': 'W', 'Ү': 'Y', 'Ӏ': 'I',
+
+	// Greek 小寫
+	'α': 'a', 'ο': 'o', 'ν': 'v', 'ι': 'i', 'κ': 'k', 'ρ': 'p', 'τ': 't', 'υ': 'u',
+	'χ': 'x', 'γ': 'y',
+
+	// Greek 大寫
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M',
+	'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
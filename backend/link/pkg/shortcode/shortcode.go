@@ -0,0 +1,109 @@
+package shortcode
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CaseFold 客製化短網址的大小寫處理方式
+type CaseFold string
+
+const (
+	CaseKeep  CaseFold = "keep"  // 保留大小寫
+	CaseLower CaseFold = "lower" // 不區分大小寫，統一轉換為小寫
+)
+
+// CaseFoldFromString 將設定值轉換為 CaseFold，無法辨識時回傳 false
+func CaseFoldFromString(s string) (CaseFold, bool) {
+	switch CaseFold(strings.ToLower(s)) {
+	case "", CaseKeep:
+		return CaseKeep, true
+	case CaseLower:
+		return CaseLower, true
+	default:
+		return "", false
+	}
+}
+
+// Normalize 將客製化短網址轉換為 NFKC 形式，並依照 fold 處理大小寫
+//
+// 全形字元、相容字元等外觀相同的寫法會被轉換為同一種形式
+func Normalize(code string, fold CaseFold) string {
+	code = norm.NFKC.String(code)
+	if fold == CaseLower {
+		code = norm.NFKC.String(cases.Fold().String(code))
+	}
+
+	return code
+}
+
+// IsValidRune 字元是否允許出現在客製化短網址中
+func IsValidRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) ||
+		r == '-' || r == '_' ||
+		unicode.Is(unicode.Han, r)
+}
+
+type script uint8
+
+const (
+	scriptOther script = iota
+	scriptLatin
+	scriptCyrillic
+	scriptGreek
+)
+
+func runeScript(r rune) script {
+	switch {
+	case unicode.Is(unicode.Latin, r):
+		return scriptLatin
+	case unicode.Is(unicode.Cyrillic, r):
+		return scriptCyrillic
+	case unicode.Is(unicode.Greek, r):
+		return scriptGreek
+	default:
+		return scriptOther
+	}
+}
+
+// IsConfusable 短網址是否可能被用來偽裝成其他短網址
+//
+// 以下情形會被視為可混淆:
+//
+// 1. 混用 Latin、Cyrillic、Greek 兩種以上的文字，且包含 confusables 表中的字元
+//
+// 2. 所有 Cyrillic、Greek 字元都在 confusables 表中，也就是整段看起來和 Latin 文字相同
+func IsConfusable(code string) bool {
+	var scriptSet [scriptGreek + 1]bool
+	var scriptNum int
+	var hasConfusable bool
+	var nonLatinNum, nonLatinConfusableNum int
+
+	for _, r := range code {
+		s := runeScript(r)
+		if s == scriptOther {
+			continue
+		}
+		if !scriptSet[s] {
+			scriptSet[s] = true
+			scriptNum++
+		}
+
+		if s != scriptLatin {
+			nonLatinNum++
+			if _, exist := confusables[r]; exist {
+				hasConfusable = true
+				nonLatinConfusableNum++
+			}
+		}
+	}
+
+	if scriptNum > 1 && hasConfusable {
+		return true
+	}
+
+	return nonLatinNum > 0 && nonLatinNum == nonLatinConfusableNum
+}
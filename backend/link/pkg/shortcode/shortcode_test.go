@@ -0,0 +1,90 @@
+package shortcode
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		code string
+		fold CaseFold
+		want string
+	}{
+		{"abc", CaseKeep, "abc"},
+		{"ABC", CaseKeep, "ABC"},
+		{"ABC", CaseLower, "abc"},
+		{"ａｂｃ１２３", CaseKeep, "abc123"},
+		{"ＡＢＣ", CaseLower, "abc"},
+		{"ﬁle", CaseKeep, "file"},
+		{"短網址", CaseLower, "短網址"},
+	}
+
+	for _, c := range cases {
+		if got := Normalize(c.code, c.fold); got != c.want {
+			t.Errorf("Normalize(%s, %s) = %s, want %s", c.code, c.fold, got, c.want)
+		}
+	}
+}
+
+func TestIsConfusable(t *testing.T) {
+	cases := []struct {
+		code string
+		want bool
+	}{
+		{"apple", false},
+		{"short-link_1", false},
+		{"短網址abc", false},
+		{"привет", false},
+		{"αβγδ", false},
+		{"а", true},      // Cyrillic а
+		{"pаypal", true}, // Latin + Cyrillic а
+		{"рау", true},    // Cyrillic "рау" looks like "pay"
+		{"аbcж", true},
+		{"abcж", false},
+	}
+
+	for _, c := range cases {
+		if got := IsConfusable(c.code); got != c.want {
+			t.Errorf("IsConfusable(%q) = %t, want %t", c.code, got, c.want)
+		}
+	}
+}
+
+func TestCaseFoldFromString(t *testing.T) {
+	for s, want := range map[string]CaseFold{"": CaseKeep, "keep": CaseKeep, "LOWER": CaseLower} {
+		got, ok := CaseFoldFromString(s)
+		if !ok || got != want {
+			t.Errorf("CaseFoldFromString(%s) = (%s, %t), want (%s, true)", s, got, ok, want)
+		}
+	}
+	if _, ok := CaseFoldFromString("upper"); ok {
+		t.Errorf("CaseFoldFromString(upper) ok = true, want false")
+	}
+}
+
+func TestTitleWords(t *testing.T) {
+	got := TitleWords("The Go Programming Language | Go Docs")
+	want := []string{"the", "go", "programming", "language", "docs"}
+	if len(got) != len(want) {
+		t.Fatalf("TitleWords() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("TitleWords() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	got := Suggestions("go", []string{"go", "docs"})
+	// 重複的候選只會出現一次，數字後綴排在最後
+	want := []string{"go-go", "go-docs", "docs", "go-1", "go-2"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Suggestions() = %v, want prefix %v", got, want)
+		}
+	}
+	for _, code := range got {
+		if code == "go" {
+			t.Fatalf("Suggestions() = %v contains the requested word", got)
+		}
+	}
+}
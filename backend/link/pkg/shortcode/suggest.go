@@ -0,0 +1,72 @@
+package shortcode
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	titleWordsMax       = 5
+	titleWordMinRuneLen = 2
+	titleWordMaxRuneLen = 20
+
+	// numberSuffixMax 最多嘗試的數字後綴，例如 word-1 ~ word-9
+	numberSuffixMax = 9
+)
+
+// TitleWords 將網頁標題切分為可以用於短網址的小寫字詞，最多回傳 5 個
+func TitleWords(title string) []string {
+	fields := strings.FieldsFunc(Normalize(title, CaseLower), func(r rune) bool {
+		return !IsValidRune(r) || r == '-' || r == '_'
+	})
+
+	words := make([]string, 0, titleWordsMax)
+	seen := make(map[string]struct{}, titleWordsMax)
+	for _, w := range fields {
+		runeLen := utf8.RuneCountInString(w)
+		if runeLen < titleWordMinRuneLen || runeLen > titleWordMaxRuneLen {
+			continue
+		}
+		if _, exist := seen[w]; exist {
+			continue
+		}
+		seen[w] = struct{}{}
+		words = append(words, w)
+		if len(words) == titleWordsMax {
+			break
+		}
+	}
+
+	return words
+}
+
+// Suggestions 根據使用者要求的 word 與目的地標題的字詞產生候選的短網址，依照建議的優先順序排列
+//
+// 回傳的短網址不一定可以使用，需要再確認是否有效、是否已被使用
+func Suggestions(word string, titleWords []string) []string {
+	list := make([]string, 0, len(titleWords)*2+1+numberSuffixMax)
+	seen := map[string]struct{}{word: {}}
+	add := func(code string) {
+		if _, exist := seen[code]; exist {
+			return
+		}
+		seen[code] = struct{}{}
+		list = append(list, code)
+	}
+
+	for _, tw := range titleWords {
+		add(word + "-" + tw)
+	}
+	for _, tw := range titleWords {
+		add(tw)
+	}
+	if len(titleWords) >= 2 {
+		add(titleWords[0] + "-" + titleWords[1])
+	}
+	for i := 1; i <= numberSuffixMax; i++ {
+		add(word + "-" + strconv.Itoa(i))
+	}
+
+	return list
+}
@@ -0,0 +1,279 @@
+package urlpolicy
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoHost             = errors.New("destination has no host")
+	ErrSchemeNotAllowed   = errors.New("destination scheme is not allowed")
+	ErrDomainBlocked      = errors.New("destination domain is blocked")
+	ErrIPLiteralForbidden = errors.New("ip literal destination is not allowed")
+	ErrPrivateNetwork     = errors.New("private network destination is not allowed")
+)
+
+// defaultSchemes 未設定 AllowSchemes 時允許的 scheme
+var defaultSchemes = []string{"http", "https"}
+
+// localSuffixes 只會在內部網路解析的網域後綴
+var localSuffixes = []string{".localhost", ".local", ".internal", ".lan", ".home.arpa"}
+
+// Options 建立 Policy 時的設定
+type Options struct {
+	AllowSchemes        []string // 允許的 scheme，為空時使用 http、https
+	AllowIPLiteral      bool     // 是否允許以 IP 作為 host
+	AllowPrivateNetwork bool     // 是否允許私有網段、本機等目的地
+}
+
+// blocklist 封鎖的網域清單
+//
+// domains 為完全相符的網域，suffixes 為網域本身與其所有子網域
+type blocklist struct {
+	domains  map[string]struct{}
+	suffixes []string
+}
+
+func newBlocklist(entries []string) *blocklist {
+	bl := &blocklist{domains: make(map[string]struct{}, len(entries))}
+	for _, entry := range entries {
+		entry = NormalizeEntry(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.HasPrefix(entry, ".") {
+			bl.suffixes = append(bl.suffixes, entry)
+		} else {
+			bl.domains[entry] = struct{}{}
+		}
+	}
+
+	return bl
+}
+
+func (bl *blocklist) match(host string) bool {
+	if _, exist := bl.domains[host]; exist {
+		return true
+	}
+	for _, suffix := range bl.suffixes {
+		if host == suffix[1:] || strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Policy 檢查目的地網址是否允許被建立為短網址
+//
+// 封鎖清單分為設定檔載入的 static 以及執行期間可變更的 runtime 兩部分
+type Policy struct {
+	schemes             map[string]struct{}
+	allowIPLiteral      bool
+	allowPrivateNetwork bool
+
+	mu      sync.RWMutex
+	static  *blocklist
+	runtime *blocklist
+}
+
+func New(opts Options) *Policy {
+	schemes := opts.AllowSchemes
+	if len(schemes) == 0 {
+		schemes = defaultSchemes
+	}
+	schemeSet := make(map[string]struct{}, len(schemes))
+	for _, s := range schemes {
+		schemeSet[strings.ToLower(s)] = struct{}{}
+	}
+
+	return &Policy{
+		schemes:             schemeSet,
+		allowIPLiteral:      opts.AllowIPLiteral,
+		allowPrivateNetwork: opts.AllowPrivateNetwork,
+		static:              newBlocklist(nil),
+		runtime:             newBlocklist(nil),
+	}
+}
+
+// NormalizeEntry 將封鎖清單的項目轉換為統一格式，無效的項目會回傳空字串
+//
+// 以 "." 或 "*." 開頭的項目代表封鎖網域本身與其所有子網域
+func NormalizeEntry(entry string) string {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	entry = strings.TrimSuffix(entry, ".")
+	if strings.HasPrefix(entry, "*.") {
+		entry = entry[1:]
+	}
+	if entry == "" || entry == "." || strings.ContainsAny(entry, " /:@") {
+		return ""
+	}
+
+	return entry
+}
+
+// LoadBlocklistFile 從檔案讀取封鎖清單，每行一個項目，"#" 之後為註解
+func (p *Policy) LoadBlocklistFile(path string) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+
+	bl := newBlocklist(entries)
+	p.mu.Lock()
+	p.static = bl
+	p.mu.Unlock()
+
+	return nil
+}
+
+// SetRuntimeBlocklist 替換執行期間的封鎖清單
+func (p *Policy) SetRuntimeBlocklist(entries []string) {
+	bl := newBlocklist(entries)
+	p.mu.Lock()
+	p.runtime = bl
+	p.mu.Unlock()
+}
+
+// IsBlocked host 是否在封鎖清單中
+func (p *Policy) IsBlocked(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.static.match(host) || p.runtime.match(host)
+}
+
+// Check 檢查目的地網址是否符合規則
+//
+// 只檢查網址本身，網域解析後的 IP 需要在實際連線時以 CheckIP 檢查
+func (p *Policy) Check(u *url.URL) error {
+	if _, allow := p.schemes[strings.ToLower(u.Scheme)]; !allow {
+		return ErrSchemeNotAllowed
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return ErrNoHost
+	}
+
+	if ip := ParseHostIP(host); ip != nil {
+		if !p.allowIPLiteral {
+			return ErrIPLiteralForbidden
+		}
+		if !p.allowPrivateNetwork && IsPrivateIP(ip) {
+			return ErrPrivateNetwork
+		}
+	} else if !p.allowPrivateNetwork && isLocalName(host) {
+		return ErrPrivateNetwork
+	}
+
+	if p.IsBlocked(host) {
+		return ErrDomainBlocked
+	}
+
+	return nil
+}
+
+// CheckIP 檢查實際連線的 IP，用於網域解析後的檢查
+func (p *Policy) CheckIP(ip net.IP) error {
+	if !p.allowPrivateNetwork && IsPrivateIP(ip) {
+		return ErrPrivateNetwork
+	}
+
+	return nil
+}
+
+// ParseHostIP 將 host 解析為 IP，host 不是 IP 時回傳 nil
+//
+// 除了標準格式外，也接受瀏覽器與 inet_aton 會解析的 IPv4 簡寫，例如 127.1、2130706433、0x7f.0.0.1
+func ParseHostIP(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+	nums := make([]uint64, len(parts))
+	for i, part := range parts {
+		num, err := parseIPv4Part(part)
+		if err != nil {
+			return nil
+		}
+		nums[i] = num
+	}
+
+	// 最後一段填滿剩下的位元組，其餘每段為一個位元組
+	var v uint64
+	for _, num := range nums[:len(nums)-1] {
+		if num > 0xff {
+			return nil
+		}
+		v = v<<8 | num
+	}
+	restBits := uint(8 * (5 - len(nums)))
+	last := nums[len(nums)-1]
+	if last >= 1<<restBits {
+		return nil
+	}
+	v = v<<restBits | last
+
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// parseIPv4Part 解析 IPv4 簡寫中的一段，0x 開頭為十六進位，0 開頭為八進位
+func parseIPv4Part(part string) (uint64, error) {
+	switch {
+	case part == "":
+		return 0, strconv.ErrSyntax
+	case strings.HasPrefix(part, "0x") || strings.HasPrefix(part, "0X"):
+		if len(part) == 2 {
+			return 0, nil
+		}
+		return strconv.ParseUint(part[2:], 16, 32)
+	case len(part) > 1 && part[0] == '0':
+		return strconv.ParseUint(part[1:], 8, 32)
+	default:
+		return strconv.ParseUint(part, 10, 32)
+	}
+}
+
+// IsPrivateIP ip 是否為私有網段、本機等只能在內部網路連線的位址
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+func isLocalName(host string) bool {
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return true
+	}
+	for _, suffix := range localSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
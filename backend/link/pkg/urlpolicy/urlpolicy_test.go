@@ -0,0 +1,120 @@
+package urlpolicy
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	u, err := url.ParseRequestURI(raw)
+	if err != nil {
+		t.Fatalf("url.ParseRequestURI(%s) failed, err=%s", raw, err)
+	}
+	return u
+}
+
+func TestCheck(t *testing.T) {
+	p := New(Options{})
+	p.SetRuntimeBlocklist([]string{"evil.com", ".phish.net"})
+
+	cases := []struct {
+		raw  string
+		want error
+	}{
+		{"https://example.com/a?b=c", nil},
+		{"HTTP://Example.com.", nil},
+		{"javascript:alert(1)", ErrSchemeNotAllowed},
+		{"data:text/html,hi", ErrSchemeNotAllowed},
+		{"file:///etc/passwd", ErrSchemeNotAllowed},
+		{"https://evil.com/", ErrDomainBlocked},
+		{"https://sub.evil.com/", nil},
+		{"https://phish.net/", ErrDomainBlocked},
+		{"https://a.b.phish.net/", ErrDomainBlocked},
+		{"https://1.1.1.1/", ErrIPLiteralForbidden},
+		{"https://localhost:8080/", ErrPrivateNetwork},
+		{"https://printer.local/", ErrPrivateNetwork},
+		{"https://intranet/", ErrPrivateNetwork},
+	}
+
+	for _, c := range cases {
+		err := p.Check(mustParse(t, c.raw))
+		if !errors.Is(err, c.want) {
+			t.Errorf("Check(%s) = %v, want %v", c.raw, err, c.want)
+		}
+	}
+}
+
+func TestCheckIPLiteral(t *testing.T) {
+	p := New(Options{AllowIPLiteral: true})
+
+	if err := p.Check(mustParse(t, "https://1.1.1.1/")); err != nil {
+		t.Errorf("public ip literal = %v, want nil", err)
+	}
+	for _, raw := range []string{"https://10.0.0.1/", "https://127.0.0.1/", "https://[::1]/", "https://169.254.169.254/",
+		"https://127.1/", "https://2130706433/", "https://0x7f000001/", "https://0177.0.0.1/", "https://10.0x1.1/"} {
+		if err := p.Check(mustParse(t, raw)); !errors.Is(err, ErrPrivateNetwork) {
+			t.Errorf("Check(%s) = %v, want %v", raw, err, ErrPrivateNetwork)
+		}
+	}
+}
+
+func TestLoadBlocklistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	content := "# comment\nbad.org\n*.worse.org # suffix\n\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write blocklist failed, err=%s", err)
+	}
+
+	p := New(Options{})
+	if err := p.LoadBlocklistFile(path); err != nil {
+		t.Fatalf("LoadBlocklistFile failed, err=%s", err)
+	}
+
+	for host, want := range map[string]bool{
+		"bad.org":      true,
+		"ok.bad.org":   false,
+		"worse.org":    true,
+		"x.worse.org":  true,
+		"notworse.org": false,
+	} {
+		if got := p.IsBlocked(host); got != want {
+			t.Errorf("IsBlocked(%s) = %t, want %t", host, got, want)
+		}
+	}
+}
+
+func TestParseHostIP(t *testing.T) {
+	cases := []struct {
+		host string
+		want string // 為空時代表不是 IP
+	}{
+		{"1.2.3.4", "1.2.3.4"},
+		{"::1", "::1"},
+		{"127.1", "127.0.0.1"},
+		{"10.1.257", "10.1.1.1"},
+		{"2130706433", "127.0.0.1"},
+		{"0x7f.1", "127.0.0.1"},
+		{"0300.0250.1.1", "192.168.1.1"},
+		{"0x", "0.0.0.0"},
+		{"1.2.3.256", ""},
+		{"1.2.3.4.5", ""},
+		{"4294967296", ""},
+		{"08.1.1.1", ""},
+		{"example.com", ""},
+		{"1.2.3.com", ""},
+		{"1..2", ""},
+	}
+
+	for _, c := range cases {
+		ip := ParseHostIP(c.host)
+		switch {
+		case c.want == "" && ip != nil:
+			t.Errorf("ParseHostIP(%s) = %s, want nil", c.host, ip)
+		case c.want != "" && (ip == nil || ip.String() != c.want):
+			t.Errorf("ParseHostIP(%s) = %v, want %s", c.host, ip, c.want)
+		}
+	}
+}
@@ -1,10 +1,43 @@
 package configs
 
-import "URLS/internal/common"
+import (
+	"URLS/internal/common"
+	"time"
+)
+
+// RedirectInfo 導向回應的設定
+type RedirectInfo struct {
+	DefaultCode     int           // 短網址沒有指定時使用的狀態碼 (301、302、307、308)，預設 302
+	PermanentMaxAge time.Duration // 301、308 回應可以被快取的時間，預設 1 小時
+}
+
+// CacheInfo 短網址資料在記憶體中的快取設定
+type CacheInfo struct {
+	// 停用存在的短網址的快取，不存在的短網址仍會被快取，避免每次都向 mongo 查詢
+	Disable       bool
+	Size          int           // 最多快取的短網址數量，預設 10000
+	TTL           time.Duration // 存在的短網址的快取時間，預設 1 分鐘
+	NegativeTTL   time.Duration // 不存在的短網址的快取時間，預設 10 秒
+	StatsInterval time.Duration // 將命中率寫入 log 的間隔，預設 5 分鐘
+}
+
+// ClicksInfo 點擊統計的設定
+type ClicksInfo struct {
+	// 將記憶體中的點擊統計寫入資料庫的間隔，預設 10 秒，
+	// 收到 SIGINT 或 SIGTERM 時會先寫入再結束，異常結束時會遺失尚未寫入的統計
+	FlushInterval time.Duration
+}
 
 // RDSCfgInfo redirector service config
 type RDSCfgInfo struct {
 	common.BaseCfgInfo `mapstructure:",squash"`
 	WebSSL             bool
 	WithoutGW          bool // 是否通過 gateway 反向代理
+
+	// 管理用 gRPC (RDService) 的監聽位址，為空時不啟用，其他 service 以 common 設定的 srvcaddrmap.rd.grpc 連線
+	AdminListenAddr string
+
+	Redirect RedirectInfo
+	Cache    CacheInfo
+	Clicks   ClicksInfo
 }
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"URLS/internal/utils/ratewindow"
+	rdPB "URLS/proto/gen/go/redirector/v1"
+	"URLS/redirector/models"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// statsWindow 即時統計的時間範圍
+	statsWindow = time.Minute
+	// statsBuckets 即時統計的時間範圍被分為的區間數量
+	statsBuckets = 60
+)
+
+// rdMetrics redirector 最近一段時間的即時統計
+type rdMetrics struct {
+	requests      *ratewindow.Counter
+	notFound      *ratewindow.Counter
+	redisCalls    *ratewindow.Counter
+	redisDuration *ratewindow.Counter // 單位為微秒
+}
+
+func newRDMetrics() *rdMetrics {
+	return &rdMetrics{
+		requests:      ratewindow.New(statsWindow, statsBuckets),
+		notFound:      ratewindow.New(statsWindow, statsBuckets),
+		redisCalls:    ratewindow.New(statsWindow, statsBuckets),
+		redisDuration: ratewindow.New(statsWindow, statsBuckets),
+	}
+}
+
+// redisLatencyHook 記錄每個 redis 指令的回應時間
+type redisLatencyHook struct {
+	metrics *rdMetrics
+}
+
+func (h redisLatencyHook) observe(start time.Time) {
+	h.metrics.redisCalls.Add(1)
+	h.metrics.redisDuration.Add(time.Since(start).Microseconds())
+}
+
+func (h redisLatencyHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h redisLatencyHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		defer h.observe(time.Now())
+		return next(ctx, cmd)
+	}
+}
+
+func (h redisLatencyHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		defer h.observe(time.Now())
+		return next(ctx, cmds)
+	}
+}
+
+// clicksFlushLoop 定期將點擊統計寫入資料庫
+func clicksFlushLoop(logger *zap.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, _, err := models.ClicksFlush(context.Background()); err != nil {
+			logger.Warn("flush clicks failed, retry next time", zap.Error(err))
+		}
+	}
+}
+
+func mLinkRecordToPBLinkRecord(record *models.LinkRecord) *rdPB.LinkRecordInfo {
+	if record == nil {
+		return nil
+	}
+
+	pbInfo := &rdPB.LinkRecordInfo{
+		Deleted:      record.Deleted,
+		Type:         int32(record.Type),
+		FullDest:     record.FullDest,
+		RedirectCode: int32(record.RedirectCode),
+	}
+	if record.Passthrough != nil {
+		pbInfo.Passthrough = true
+		pbInfo.PassthroughConflict = int32(record.Passthrough.Conflict)
+		pbInfo.PassthroughAllow = record.Passthrough.Allow
+	}
+	return pbInfo
+}
+
+func (rd *RedirectorController) Ping(ctx context.Context, req *emptypb.Empty) (resp *rdPB.PingResponse, err error) {
+	resp = &rdPB.PingResponse{
+		Msg: "pong",
+	}
+	return resp, nil
+}
+
+func (rd *RedirectorController) RecordInspect(ctx context.Context,
+	req *rdPB.RecordInspectRequest) (resp *rdPB.RecordInspectResponse, err error) {
+	if err = rd.IsInternalCall(ctx); err != nil {
+		return
+	}
+	if req.GetShort() == "" {
+		err = status.Error(codes.InvalidArgument, "short can not be empty")
+		return
+	}
+
+	record, exist, err := models.LinkRecordInspect(ctx, req.GetShort(), req.GetHost())
+	if err != nil {
+		return
+	}
+	cachedRecord, cached := models.LinkCachePeek(req.GetShort(), req.GetHost())
+
+	resp = &rdPB.RecordInspectResponse{
+		RedisExist:   exist,
+		RedisRecord:  mLinkRecordToPBLinkRecord(record),
+		Cached:       cached,
+		CachedRecord: mLinkRecordToPBLinkRecord(cachedRecord),
+	}
+	return resp, nil
+}
+
+func (rd *RedirectorController) CacheInvalidate(ctx context.Context,
+	req *rdPB.CacheInvalidateRequest) (resp *rdPB.CacheInvalidateResponse, err error) {
+	if err = rd.IsInternalCall(ctx); err != nil {
+		return
+	}
+
+	if req.GetAll() {
+		err = models.LinkCachePurge(ctx)
+	} else if req.GetShort() == "" {
+		err = status.Error(codes.InvalidArgument, "short can not be empty")
+	} else {
+		err = models.LinkCacheInvalidate(ctx, req.GetShort(), req.GetHost())
+	}
+	if err != nil {
+		return
+	}
+
+	resp = &rdPB.CacheInvalidateResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (rd *RedirectorController) Stats(ctx context.Context, req *emptypb.Empty) (resp *rdPB.StatsResponse, err error) {
+	if err = rd.IsInternalCall(ctx); err != nil {
+		return
+	}
+
+	resp = &rdPB.StatsResponse{
+		RequestsPerSec: rd.metrics.requests.Rate(),
+	}
+	if requests := rd.metrics.requests.Sum(); requests > 0 {
+		resp.NotFoundRate = float64(rd.metrics.notFound.Sum()) / float64(requests)
+	}
+	if calls := rd.metrics.redisCalls.Sum(); calls > 0 {
+		resp.RedisLatencyMs = float64(rd.metrics.redisDuration.Sum()) / float64(calls) / 1000
+	}
+	if cacheStats, enabled := models.LinkCacheStats(); enabled {
+		resp.CacheHits = cacheStats.Hits
+		resp.CacheMisses = cacheStats.Misses
+		resp.CacheLen = int64(cacheStats.Len)
+	}
+	linkNum, clickNum := models.ClicksPending()
+	resp.PendingClickLinks = int64(linkNum)
+	resp.PendingClicks = clickNum
+
+	return resp, nil
+}
+
+func (rd *RedirectorController) ClicksFlush(ctx context.Context, req *emptypb.Empty) (resp *rdPB.ClicksFlushResponse, err error) {
+	if err = rd.IsInternalCall(ctx); err != nil {
+		return
+	}
+
+	linkNum, clickNum, err := models.ClicksFlush(ctx)
+	if err != nil {
+		return
+	}
+
+	resp = &rdPB.ClicksFlushResponse{
+		FlushedLinks:  int64(linkNum),
+		FlushedClicks: clickNum,
+	}
+	return resp, nil
+}
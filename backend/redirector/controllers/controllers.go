@@ -3,9 +3,14 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 
 	"URLS/internal/common"
 	linkModels "URLS/link/models"
+	"URLS/link/pkg/shortcode"
+	rdPB "URLS/proto/gen/go/redirector/v1"
 	"URLS/redirector/configs"
 	"URLS/redirector/models"
 
@@ -16,16 +21,43 @@ import (
 
 type RedirectorController struct {
 	*common.BaseController
+	rdPB.UnimplementedRDServiceServer
 	cfg *configs.RDSCfgInfo
 
-	handler fasthttp.RequestHandler
+	handler  fasthttp.RequestHandler
+	caseFold shortcode.CaseFold
+
+	redirectCode int    // 短網址沒有指定時使用的狀態碼
+	permanentCC  string // 301、308 回應的 Cache-Control
 
 	redisDB *redis.Client
+	metrics *rdMetrics
 }
 
 const RedirectorRedisIdx = 2
 
 func NewRDController(cfgInfo *configs.RDSCfgInfo, logger *zap.Logger) (ctrl *RedirectorController, err error) {
+	caseFold, convOK := shortcode.CaseFoldFromString(cfgInfo.ShortCode.CaseFold)
+	if !convOK {
+		err = fmt.Errorf("unknow short code case fold \"%s\"", cfgInfo.ShortCode.CaseFold)
+		logger.Error("shortcode.CaseFoldFromString failed", zap.Error(err))
+		return
+	}
+
+	redirectCode := cfgInfo.Redirect.DefaultCode
+	if redirectCode == 0 {
+		redirectCode = http.StatusFound
+	}
+	if !linkModels.RedirectCodeValid(redirectCode) {
+		err = fmt.Errorf("redirect default code %d is invalid", redirectCode)
+		logger.Error("redirect config check failed", zap.Error(err))
+		return
+	}
+	permanentMaxAge := cfgInfo.Redirect.PermanentMaxAge
+	if permanentMaxAge <= 0 {
+		permanentMaxAge = time.Hour
+	}
+
 	bc, err := common.NewBaseController(&cfgInfo.BaseCfgInfo, logger)
 	if err != nil {
 		logger.Error("common.NewBaseController failed", zap.Error(err))
@@ -40,6 +72,8 @@ func NewRDController(cfgInfo *configs.RDSCfgInfo, logger *zap.Logger) (ctrl *Red
 	}
 	redisOpts.DB = RedirectorRedisIdx
 	rClient := redis.NewClient(redisOpts)
+	metrics := newRDMetrics()
+	rClient.AddHook(redisLatencyHook{metrics: metrics})
 
 	bgCtx := context.Background()
 	_, err = rClient.Ping(bgCtx).Result()
@@ -49,23 +83,75 @@ func NewRDController(cfgInfo *configs.RDSCfgInfo, logger *zap.Logger) (ctrl *Red
 	}
 
 	// init models
-	err = linkModels.InitModels(bgCtx, bc.MgoDB, logger)
+	err = linkModels.InitModels(bgCtx, bc.MgoClient, bc.MgoDB, logger)
 	if err != nil {
 		err = fmt.Errorf("InitIndex failed, err=%s", err)
 		return
 	}
 	models.InitModels(rClient, logger)
+	cacheCfgDefault(&cfgInfo.Cache)
+	cacheOpts := models.LinkCacheOptions{
+		Size:        cfgInfo.Cache.Size,
+		TTL:         cfgInfo.Cache.TTL,
+		NegativeTTL: cfgInfo.Cache.NegativeTTL,
+	}
+	if cfgInfo.Cache.Disable {
+		cacheOpts.TTL = 0
+	}
+	models.InitLinkCache(bgCtx, cacheOpts)
+	go linkCacheStatsLoop(logger, cfgInfo.Cache.StatsInterval)
+
+	clicksFlushInterval := cfgInfo.Clicks.FlushInterval
+	if clicksFlushInterval <= 0 {
+		clicksFlushInterval = 10 * time.Second
+	}
+	go clicksFlushLoop(logger, clicksFlushInterval)
 
 	ctrl = &RedirectorController{
 		BaseController: bc,
 		cfg:            cfgInfo,
 		redisDB:        rClient,
+		metrics:        metrics,
+		caseFold:       caseFold,
+		redirectCode:   redirectCode,
+		permanentCC:    "public, max-age=" + strconv.Itoa(int(permanentMaxAge.Seconds())),
 	}
 	ctrl.handler = ctrl.redirectorHandler
 
 	return ctrl, nil
 }
 
+// cacheCfgDefault 設定快取未設定的值
+func cacheCfgDefault(cfg *configs.CacheInfo) {
+	if cfg.Size <= 0 {
+		cfg.Size = 10000
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.NegativeTTL <= 0 {
+		cfg.NegativeTTL = 10 * time.Second
+	}
+	if cfg.StatsInterval <= 0 {
+		cfg.StatsInterval = 5 * time.Minute
+	}
+}
+
+// linkCacheStatsLoop 定期將快取的命中率寫入 log
+func linkCacheStatsLoop(logger *zap.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		stats, _ := models.LinkCacheStats()
+		logger.Info("link cache stats",
+			zap.Uint64("hits", stats.Hits),
+			zap.Uint64("misses", stats.Misses),
+			zap.Float64("hitRatio", stats.HitRatio()),
+			zap.Int("len", stats.Len))
+	}
+}
+
 func (rd *RedirectorController) GetRestHandler() func(ctx *fasthttp.RequestCtx) {
 	return rd.handler
 }
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	linkModels "URLS/link/models"
+	"URLS/redirector/models"
+
+	"github.com/valyala/fasthttp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// errorPageCSP 自訂錯誤頁面的 Content-Security-Policy，不允許執行 script 與連線
+const errorPageCSP = "sandbox; default-src 'none'; img-src https: data:; style-src 'unsafe-inline'"
+
+// builtinErrorPage 沒有設定錯誤頁面時使用的頁面
+var builtinErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>body{font-family:sans-serif;text-align:center;padding:10vh 1em;color:#333}a{color:#1a73e8}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+<p><a href="{{.WebURL}}">{{.WebURL}}</a></p>
+</body>
+</html>
+`))
+
+type builtinErrorPageData struct {
+	Title   string
+	Message string
+	WebURL  string
+}
+
+// linkStateStatusCode 短網址狀態對應的 HTTP 狀態碼
+func linkStateStatusCode(state linkModels.LinkState) int {
+	if state == linkModels.LSDeleted {
+		return http.StatusGone
+	}
+	return http.StatusNotFound
+}
+
+// errorPage 回應無法導向的短網址，依序使用建立者、網域設定的錯誤頁面，都沒有設定時使用內建的頁面
+//
+// 設定為網址時以 302 導向，其他情況直接以 404 或 410 回應頁面
+func (rd *RedirectorController) errorPage(ctx *fasthttp.RequestCtx, host string,
+	creator primitive.ObjectID, state linkModels.LinkState) {
+	ctx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-store")
+
+	page, err := models.ErrorPageGet(ctx, host, creator, state)
+	if err != nil {
+		// 查詢失敗時仍然回應內建的頁面
+		rd.Logger.Warn("models.ErrorPageGet failed", zap.Error(err))
+	}
+
+	switch {
+	case page != nil && page.FallbackURL != "":
+		ctx.Redirect(page.FallbackURL, http.StatusFound)
+	case page != nil:
+		ctx.Response.Header.Set("Content-Security-Policy", errorPageCSP)
+		ctx.SetContentType("text/html; charset=utf-8")
+		ctx.SetStatusCode(linkStateStatusCode(state))
+		_, _ = ctx.WriteString(page.Template)
+	default:
+		rd.builtinErrorPage(ctx, state)
+	}
+}
+
+func (rd *RedirectorController) builtinErrorPage(ctx *fasthttp.RequestCtx, state linkModels.LinkState) {
+	data := builtinErrorPageData{
+		Title:   "Link not found",
+		Message: "The short link you visited does not exist.",
+		WebURL:  rd.webURL("/"),
+	}
+	if state == linkModels.LSDeleted {
+		data.Title = "Link deleted"
+		data.Message = "The short link you visited has been deleted."
+	}
+
+	var buf bytes.Buffer
+	if err := builtinErrorPage.Execute(&buf, data); err != nil {
+		rd.Logger.Error("builtinErrorPage.Execute failed", zap.Error(err))
+	}
+	ctx.SetContentType("text/html; charset=utf-8")
+	ctx.SetStatusCode(linkStateStatusCode(state))
+	_, _ = ctx.Write(buf.Bytes())
+}
@@ -4,27 +4,44 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"URLS/internal/common"
 	"URLS/internal/utils/strconvext"
 	linkModels "URLS/link/models"
+	"URLS/link/pkg/prefixpath"
+	"URLS/link/pkg/shortcode"
 	"URLS/redirector/models"
 
 	"github.com/mileusna/useragent"
 	"github.com/valyala/fasthttp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 )
 
+// allowMethods redirector 接受的 HTTP method
+const allowMethods = "GET, HEAD, OPTIONS"
+
+// methodCheck 檢查請求的 method，OPTIONS 與不接受的 method 會在此回應並回傳 false
+//
+// HEAD 與 GET 的處理方式相同，fasthttp 會省略 HEAD 回應的 body
 func methodCheck(ctx *fasthttp.RequestCtx) bool {
-	if !ctx.Request.Header.IsGet() {
-		ctx.Response.Header.SetStatusCode(http.StatusMethodNotAllowed)
-		return false
+	switch {
+	case ctx.IsGet(), ctx.IsHead():
+		return true
+	case ctx.IsOptions():
+		ctx.Response.Header.Set(fasthttp.HeaderAllow, allowMethods)
+		ctx.SetStatusCode(http.StatusNoContent)
+	default:
+		ctx.Response.Header.Set(fasthttp.HeaderAllow, allowMethods)
+		ctx.SetStatusCode(http.StatusMethodNotAllowed)
 	}
 
-	return true
+	return false
 }
 
-func (rd *RedirectorController) webReirect(ctx *fasthttp.RequestCtx, p string) {
+// webURL 回傳 web 服務中路徑 p 的網址
+func (rd *RedirectorController) webURL(p string) string {
 	var scheme string
 	if rd.cfg.WebSSL {
 		scheme = "https"
@@ -32,17 +49,11 @@ func (rd *RedirectorController) webReirect(ctx *fasthttp.RequestCtx, p string) {
 		scheme = "http"
 	}
 
-	ctx.Redirect(scheme+"://"+rd.cfg.WebDomain+"/web"+p, http.StatusFound)
-}
-
-func (rd *RedirectorController) notFoundRedirect(ctx *fasthttp.RequestCtx) {
-	// redirect to not found page
-	rd.webReirect(ctx, "/link-error/not-found")
+	return scheme + "://" + rd.cfg.WebDomain + "/web" + p
 }
 
-func (rd *RedirectorController) deletedRedirect(ctx *fasthttp.RequestCtx) {
-	// redirect to deleted link page
-	rd.webReirect(ctx, "/link-error/deleted")
+func (rd *RedirectorController) webReirect(ctx *fasthttp.RequestCtx, p string) {
+	ctx.Redirect(rd.webURL(p), http.StatusFound)
 }
 
 func (rd *RedirectorController) redirectorHandler(ctx *fasthttp.RequestCtx) {
@@ -50,19 +61,16 @@ func (rd *RedirectorController) redirectorHandler(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	rd.metrics.requests.Add(1)
+
 	reqPath := ctx.Path()
 	pathLen := len(reqPath)
 	fmt.Println(string(reqPath))
 	switch strconvext.B2S(reqPath) {
-	case "/", "/favicon.ico":
+	case common.RDRootPath, common.RDFaviconPath:
 		rd.webReirect(ctx, string(reqPath))
 		return
 	}
-	if pathLen < 2 {
-		rd.notFoundRedirect(ctx)
-		return
-	}
-	shortPath := string(reqPath[1:])
 
 	var reqHost string
 	ctxHostStr := string(ctx.Host())
@@ -70,88 +78,174 @@ func (rd *RedirectorController) redirectorHandler(ctx *fasthttp.RequestCtx) {
 		reqHost = ctxHostStr
 	}
 
-	dest, deleted, exist, err := models.LinkGetInfo(ctx, shortPath, reqHost)
+	if pathLen < 2 {
+		rd.metrics.notFound.Add(1)
+		rd.errorPage(ctx, reqHost, primitive.NilObjectID, linkModels.LSNotFound)
+		return
+	}
+	shortPath := string(reqPath[1:])
+
+	record, short, restPath, exist, err := linkResolve(shortPath,
+		func(short string, fallback bool) (*models.LinkRecord, string, bool, error) {
+			return rd.linkRecordGet(ctx, short, reqHost, fallback)
+		})
 	if err != nil {
 		rd.Logger.Error("models.LinkGetInfo failed", zap.Error(err))
 		ctx.SetStatusCode(http.StatusInternalServerError)
 		_, _ = ctx.WriteString(common.ErrMsgInternal)
 		return
 	}
-	if deleted {
-		rd.deletedRedirect(ctx)
+	if !exist {
+		rd.metrics.notFound.Add(1)
+		rd.errorPage(ctx, reqHost, primitive.NilObjectID, linkModels.LSNotFound)
 		return
 	}
-	if !exist {
-		rd.notFoundRedirect(ctx)
+	if record.Deleted {
+		rd.errorPage(ctx, reqHost, record.Creator, linkModels.LSDeleted)
 		return
 	}
 
-	ctx.Redirect(dest, http.StatusMovedPermanently)
-	go rd.sourceAnalyze(shortPath, reqHost,
+	dest := record.FullDest
+	if restPath != "" {
+		dest = prefixpath.Append(dest, restPath)
+	}
+	if record.Passthrough != nil && len(ctx.URI().QueryString()) > 0 {
+		// 無法解析的參數會被忽略
+		incoming, _ := url.ParseQuery(string(ctx.URI().QueryString()))
+		dest = record.Passthrough.Apply(dest, incoming)
+	}
+
+	rd.redirect(ctx, dest, record.RedirectCode)
+	if ctx.IsHead() {
+		// 連結檢查與預覽等工具發出的 HEAD 請求不計入點擊
+		return
+	}
+	go rd.sourceAnalyze(short, reqHost,
 		string(ctx.Request.Header.UserAgent()),
 		string(ctx.Request.Header.Peek(common.HderNameGWIP)),
 		string(ctx.Request.Header.Peek(common.HderNameGWCountry)))
 }
 
-// sourceAnalyze 來源解析
+// redirect 以 code 導向到 dest 並設定對應的 Cache-Control，code 為 0 時使用預設的狀態碼
+//
+// 永久導向只允許快取設定的時間，暫時導向不允許快取，以便之後的修改與點擊統計
+func (rd *RedirectorController) redirect(ctx *fasthttp.RequestCtx, dest string, code int) {
+	if code == 0 {
+		code = rd.redirectCode
+	}
+
+	switch code {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		ctx.Response.Header.Set(fasthttp.HeaderCacheControl, rd.permanentCC)
+	default:
+		ctx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-store")
+	}
+	ctx.Redirect(dest, code)
+}
+
+// linkGetFunc 查詢短網址資料，回傳實際查詢到的短網址，fallback 為 false 時只查詢 redis
+type linkGetFunc func(short string, fallback bool) (
+	record *models.LinkRecord, foundShort string, exist bool, err error)
+
+// linkResolve 先以完整路徑查詢，查詢不到時再以第一段路徑查詢前綴短網址，
+// restPath 為要加到前綴短網址目的地後面的路徑
+//
+// 第一段路徑查詢到的短網址不是前綴短網址時視為不存在，已刪除的前綴短網址會直接回傳，
+// 沒有類型的舊資料視為不存在。
+// 兩次查詢在 redis 中沒有資料時都會查詢 mongo，不存在的結果會被快取，避免同一個路徑重複查詢 mongo
+func linkResolve(shortPath string, get linkGetFunc) (
+	record *models.LinkRecord, short, restPath string, exist bool, err error) {
+	record, short, exist, err = get(shortPath, true)
+	if err != nil || exist {
+		return
+	}
+
+	first, rest, ok := prefixpath.Split(shortPath)
+	if !ok {
+		return
+	}
+	record, short, exist, err = get(first, true)
+	if err != nil || !exist {
+		return nil, short, "", false, err
+	}
+	if record.Type != linkModels.LTPrefix {
+		return nil, short, "", false, nil
+	}
+
+	return record, short, rest, true, nil
+}
+
+// linkRecordGet 查詢短網址資料，回傳實際查詢到的短網址
+//
+// 正規化後的查詢與原本的短網址使用相同的 fallback
+func (rd *RedirectorController) linkRecordGet(ctx context.Context, short, host string, fallback bool) (
+	record *models.LinkRecord, foundShort string, exist bool, err error) {
+	record, exist, err = models.LinkGetInfo(ctx, short, host, fallback)
+	if err == nil && !exist {
+		// 客製化短網址以正規化後的形式儲存，查詢不到時改用正規化後的短網址查詢
+		normalized := shortcode.Normalize(short, rd.caseFold)
+		if normalized != short {
+			short = normalized
+			record, exist, err = models.LinkGetInfo(ctx, short, host, fallback)
+		}
+	}
+
+	return record, short, exist, err
+}
+
+// sourceAnalyze 來源解析，解析結果會被加到點擊統計中，由 clicksFlushLoop 寫入資料庫
 func (rd *RedirectorController) sourceAnalyze(short, host, uaStr, ip, country string) {
-	countryClick := make(map[string]uint64, 1)
+	source := &models.ClickSource{Country: country}
 	if country == "" {
 		// TODO: 透過 IP 庫查詢國家
 		_ = ip
-	} else {
-		countryClick[country] = 1
 	}
 
 	// ua parse
 
 	ua := useragent.Parse(uaStr)
 
-	osClick := make(map[string]uint64, 1)
 	if ua.IsWindows() {
-		osClick["windows"] = 1
+		source.OS = "windows"
 	} else if ua.IsLinux() {
-		osClick["linux"] = 1
+		source.OS = "linux"
 	} else if ua.IsMacOS() {
-		osClick["macos"] = 1
+		source.OS = "macos"
 	} else if ua.IsAndroid() {
-		osClick["android"] = 1
+		source.OS = "android"
 	} else if ua.IsIOS() {
-		osClick["ios"] = 1
+		source.OS = "ios"
 	} else {
-		osClick["other"] = 1
+		source.OS = "other"
 	}
 
-	deviceClick := make(map[string]uint64, 1)
 	if ua.Desktop {
-		deviceClick["desktop"] = 1
+		source.Device = "desktop"
 	} else if ua.Mobile {
-		deviceClick["mobile"] = 1
+		source.Device = "mobile"
 	} else if ua.Tablet {
-		deviceClick["tablet"] = 1
+		source.Device = "tablet"
 	} else {
-		deviceClick["other"] = 1
+		source.Device = "other"
 	}
 
-	browserClick := make(map[string]uint64, 1)
 	if ua.IsFirefox() {
-		browserClick["firefox"] = 1
+		source.Browser = "firefox"
 	} else if ua.IsEdge() {
-		browserClick["edge"] = 1
+		source.Browser = "edge"
 	} else if ua.IsOpera() {
-		browserClick["opera"] = 1
+		source.Browser = "opera"
 	} else if ua.IsOperaMini() {
-		browserClick["opera"] = 1
+		source.Browser = "opera"
 	} else if ua.IsChrome() {
-		browserClick["chrome"] = 1
+		source.Browser = "chrome"
 	} else if ua.IsSafari() {
-		browserClick["safari"] = 1
+		source.Browser = "safari"
 	} else if ua.IsInternetExplorer() {
-		browserClick["ie"] = 1
+		source.Browser = "ie"
 	} else {
-		browserClick["other"] = 1
+		source.Browser = "other"
 	}
 
-	bgCTX := context.Background()
-	_ = linkModels.LinkClicksUpdate(bgCTX, short, host, 1, countryClick, osClick, deviceClick, browserClick)
+	models.ClickAdd(short, host, source)
 }
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	linkModels "URLS/link/models"
+	"URLS/redirector/models"
+)
+
+func TestLinkResolve(t *testing.T) {
+	records := map[string]*models.LinkRecord{
+		"docs":     {Type: linkModels.LTPrefix, FullDest: "https://docs.example.com"},
+		"docs/faq": {Type: linkModels.LTDirect, FullDest: "https://faq.example.com"},
+		"abc":      {Type: linkModels.LTDirect, FullDest: "https://a.com"},
+		"old":      {Deleted: true, Type: linkModels.LTDirect},
+		"gone":     {Deleted: true, Type: linkModels.LTPrefix},
+		"legacy":   {Deleted: true},
+	}
+	errBroken := errors.New("broken")
+
+	tests := []struct {
+		path      string
+		wantShort string
+		wantRest  string
+		wantExist bool
+		wantErr   error
+		wantCalls []string // 允許 fallback 的查詢以 "*" 標示
+	}{
+		// 完整路徑優先於前綴短網址，redis 中沒有資料時前綴短網址的查詢也會查詢 mongo
+		{"docs/faq", "docs/faq", "", true, nil, []string{"docs/faq*"}},
+		{"docs/getting-started", "docs", "getting-started", true, nil, []string{"docs/getting-started*", "docs*"}},
+		{"docs", "docs", "", true, nil, []string{"docs*"}},
+		// 第一段為一般短網址時不會被當作前綴
+		{"abc/x", "", "", false, nil, []string{"abc/x*", "abc*"}},
+		// 第一段為已刪除的短網址時，只有前綴短網址會回傳已刪除
+		{"old/x", "", "", false, nil, []string{"old/x*", "old*"}},
+		{"gone/x", "gone", "x", true, nil, []string{"gone/x*", "gone*"}},
+		{"legacy/x", "", "", false, nil, []string{"legacy/x*", "legacy*"}},
+		{"old", "old", "", true, nil, []string{"old*"}},
+		{"none", "", "", false, nil, []string{"none*"}},
+		{"none/x", "", "", false, nil, []string{"none/x*", "none*"}},
+		{"broken/x", "", "", false, errBroken, []string{"broken/x*"}},
+	}
+
+	for _, tt := range tests {
+		var calls []string
+		get := func(short string, fallback bool) (*models.LinkRecord, string, bool, error) {
+			if fallback {
+				calls = append(calls, short+"*")
+			} else {
+				calls = append(calls, short)
+			}
+			if short == "broken/x" {
+				return nil, "", false, errBroken
+			}
+			record, exist := records[short]
+			if !exist {
+				return nil, "", false, nil
+			}
+			return record, short, true, nil
+		}
+
+		record, short, rest, exist, err := linkResolve(tt.path, get)
+		if !errors.Is(err, tt.wantErr) || exist != tt.wantExist {
+			t.Errorf("linkResolve(%q) exist=%t err=%v, want exist=%t err=%v",
+				tt.path, exist, err, tt.wantExist, tt.wantErr)
+			continue
+		}
+		if exist && (short != tt.wantShort || rest != tt.wantRest || record != records[tt.wantShort]) {
+			t.Errorf("linkResolve(%q) = (%q, %q), want (%q, %q)", tt.path, short, rest, tt.wantShort, tt.wantRest)
+		}
+		if !reflect.DeepEqual(calls, tt.wantCalls) {
+			t.Errorf("linkResolve(%q) lookups = %v, want %v", tt.path, calls, tt.wantCalls)
+		}
+	}
+}
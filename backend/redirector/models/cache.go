@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"URLS/internal/common"
+	"URLS/internal/utils/lrucache"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// linkInvalidateChannel 短網址資料被修改時，通知各個 redirector 移除快取的 channel
+const linkInvalidateChannel = "link-invalidate"
+
+// linkCachePurgeMsg 移除所有快取的通知，短網址的 key 都包含 shSplit，不會與其相同
+const linkCachePurgeMsg = "*"
+
+// LinkCacheOptions 短網址資料在 redirector 記憶體中的快取設定
+type LinkCacheOptions struct {
+	Size        int           // 最多快取的短網址數量
+	TTL         time.Duration // 存在的短網址的快取時間，為 0 時不快取
+	NegativeTTL time.Duration // 不存在的短網址的快取時間
+}
+
+// linkCache 為 nil 時不使用快取，不存在的短網址以 nil 快取
+var linkCache *lrucache.Cache[string, *LinkRecord]
+var linkCacheOpts LinkCacheOptions
+
+// linkCacheGen 每次收到移除快取的通知時增加，
+// 用來避免將通知前從 redis 讀取到的舊資料寫入快取
+var linkCacheGen atomic.Uint64
+
+// InitLinkCache 啟用快取並開始接收移除快取的通知，只有 redirector 需要呼叫
+func InitLinkCache(ctx context.Context, opts LinkCacheOptions) {
+	linkCacheOpts = opts
+	linkCache = lrucache.New[string, *LinkRecord](opts.Size)
+
+	pubsub := redisDB.Subscribe(ctx, linkInvalidateChannel)
+	go func() {
+		defer pubsub.Close()
+		for msg := range pubsub.ChannelWithSubscriptions() {
+			linkCacheGen.Add(1)
+			switch msg := msg.(type) {
+			case *redis.Subscription:
+				// 訂閱 (重新) 連線成功，斷線期間的通知已遺失，移除所有快取
+				if msg.Kind == "subscribe" {
+					linkCache.Purge()
+				}
+			case *redis.Message:
+				if msg.Payload == linkCachePurgeMsg {
+					linkCache.Purge()
+					continue
+				}
+				linkCache.Delete(msg.Payload)
+			}
+		}
+	}()
+}
+
+// LinkCacheStats 回傳快取的使用統計，未啟用快取時回傳 false
+func LinkCacheStats() (stats lrucache.Stats, enabled bool) {
+	if linkCache == nil {
+		return stats, false
+	}
+	return linkCache.Stats(), true
+}
+
+// linkCacheGet 從快取取得短網址資料，hit 為 false 時需要從 redis 讀取
+func linkCacheGet(key string) (record *LinkRecord, hit bool) {
+	if linkCache == nil {
+		return nil, false
+	}
+	return linkCache.Get(key)
+}
+
+// linkCacheSet 快取從 redis 讀取到的資料，record 為 nil 代表短網址不存在
+//
+// gen 為讀取 redis 前的 linkCacheGen，讀取期間收到通知時不寫入快取
+func linkCacheSet(key string, record *LinkRecord, gen uint64) {
+	if linkCache == nil || linkCacheGen.Load() != gen {
+		return
+	}
+
+	ttl := linkCacheOpts.TTL
+	if record == nil {
+		ttl = linkCacheOpts.NegativeTTL
+	}
+	if ttl > 0 {
+		linkCache.Set(key, record, ttl)
+	}
+}
+
+// linkCacheInvalidate 通知所有 redirector 移除 key 的快取
+//
+// 通知失敗不影響寫入的結果，快取會在過期後更新
+func linkCacheInvalidate(ctx context.Context, key string) {
+	err := redisDB.Publish(ctx, linkInvalidateChannel, key).Err()
+	if err != nil {
+		logger.Warn("publish link cache invalidation failed", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// LinkCacheInvalidate 通知所有 redirector 移除 (short, host) 的快取
+func LinkCacheInvalidate(ctx context.Context, short, host string) (err error) {
+	err = redisDB.Publish(ctx, linkInvalidateChannel, linkKey(short, host)).Err()
+	if err != nil {
+		logger.Error("publish link cache invalidation failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
+
+// LinkCachePurge 通知所有 redirector 移除所有快取
+func LinkCachePurge(ctx context.Context) (err error) {
+	err = redisDB.Publish(ctx, linkInvalidateChannel, linkCachePurgeMsg).Err()
+	if err != nil {
+		logger.Error("publish link cache purge failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
+
+// LinkCachePeek 回傳 (short, host) 在快取中的資料，不影響使用統計，record 為 nil 代表快取為不存在
+func LinkCachePeek(short, host string) (record *LinkRecord, cached bool) {
+	if linkCache == nil {
+		return nil, false
+	}
+	return linkCache.Peek(linkKey(short, host))
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"sync"
+
+	linkModels "URLS/link/models"
+
+	"google.golang.org/grpc/status"
+)
+
+// ClickSource 一次點擊的來源分類
+type ClickSource struct {
+	Country string // ISO3166，未知時為空
+	OS      string
+	Device  string
+	Browser string
+}
+
+// linkClicks 同一個短網址尚未寫入資料庫的點擊統計
+type linkClicks struct {
+	short, host string
+
+	total   uint64
+	country map[string]uint64
+	os      map[string]uint64
+	device  map[string]uint64
+	browser map[string]uint64
+}
+
+func newLinkClicks(short, host string) *linkClicks {
+	return &linkClicks{
+		short:   short,
+		host:    host,
+		country: make(map[string]uint64),
+		os:      make(map[string]uint64),
+		device:  make(map[string]uint64),
+		browser: make(map[string]uint64),
+	}
+}
+
+// merge 將 o 的統計加到 c 中
+func (c *linkClicks) merge(o *linkClicks) {
+	c.total += o.total
+	countMerge(c.country, o.country)
+	countMerge(c.os, o.os)
+	countMerge(c.device, o.device)
+	countMerge(c.browser, o.browser)
+}
+
+func countMerge(dst, src map[string]uint64) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+var (
+	clicksMu      sync.Mutex
+	clicksPending = make(map[string]*linkClicks) // key 為 linkKey
+)
+
+// ClickAdd 將一次點擊加到尚未寫入資料庫的統計中，由 ClicksFlush 寫入
+func ClickAdd(short, host string, source *ClickSource) {
+	clicksMu.Lock()
+	defer clicksMu.Unlock()
+
+	key := linkKey(short, host)
+	c, exist := clicksPending[key]
+	if !exist {
+		c = newLinkClicks(short, host)
+		clicksPending[key] = c
+	}
+	c.total++
+	if source.Country != "" {
+		c.country[source.Country]++
+	}
+	c.os[source.OS]++
+	c.device[source.Device]++
+	c.browser[source.Browser]++
+}
+
+// ClicksPending 回傳尚未寫入資料庫的短網址數量與點擊次數
+func ClicksPending() (linkNum int, clickNum uint64) {
+	clicksMu.Lock()
+	defer clicksMu.Unlock()
+
+	for _, c := range clicksPending {
+		clickNum += c.total
+	}
+	return len(clicksPending), clickNum
+}
+
+// ClicksFlush 將尚未寫入的點擊統計寫入資料庫，回傳成功寫入的短網址數量與點擊次數
+//
+// 寫入失敗的統計會被放回，在下次 flush 時重新寫入
+func ClicksFlush(ctx context.Context) (linkNum int, clickNum uint64, err error) {
+	clicksMu.Lock()
+	pending := clicksPending
+	clicksPending = make(map[string]*linkClicks, len(pending))
+	clicksMu.Unlock()
+
+	failed := make(map[string]*linkClicks)
+	for key, c := range pending {
+		if ctx.Err() != nil {
+			failed[key] = c
+			continue
+		}
+		updateErr := linkModels.LinkClicksUpdate(ctx, c.short, c.host, c.total, c.country, c.os, c.device, c.browser)
+		if updateErr != nil {
+			failed[key] = c
+			err = updateErr
+			continue
+		}
+		linkNum++
+		clickNum += c.total
+	}
+	if err == nil && ctx.Err() != nil {
+		err = status.FromContextError(ctx.Err()).Err()
+	}
+
+	if len(failed) > 0 {
+		clicksMu.Lock()
+		for key, c := range failed {
+			if cur, exist := clicksPending[key]; exist {
+				c.merge(cur)
+			}
+			clicksPending[key] = c
+		}
+		clicksMu.Unlock()
+	}
+
+	return linkNum, clickNum, err
+}
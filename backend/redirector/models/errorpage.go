@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"URLS/internal/utils/lrucache"
+	linkModels "URLS/link/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// errorPageCacheSize 最多快取的錯誤頁面數量
+	errorPageCacheSize = 1000
+	// errorPageCacheTTL 錯誤頁面的快取時間，修改後最多需要這段時間才會生效
+	errorPageCacheTTL = time.Minute
+)
+
+// errorPageCache 不存在的錯誤頁面以 nil 快取
+var errorPageCache = lrucache.New[string, *linkModels.ErrorPageInfo](errorPageCacheSize)
+
+// ErrorPageGet 取得短網址在 state 時要顯示的錯誤頁面，沒有設定時回傳 nil
+//
+// 優先使用建立者的設定，其次為網域的設定，creator 為空時只查詢網域的設定。
+// 建立者的設定只使用 FallbackURL，舊資料中的 Template 會被忽略
+func ErrorPageGet(ctx context.Context, host string, creator primitive.ObjectID,
+	state linkModels.LinkState) (page *linkModels.ErrorPageInfo, err error) {
+	if !creator.IsZero() {
+		page, err = errorPageGet(ctx, linkModels.EPScopeUser, "", creator, state)
+		if err != nil || (page != nil && page.FallbackURL != "") {
+			return
+		}
+	}
+
+	return errorPageGet(ctx, linkModels.EPScopeHost, host, primitive.NilObjectID, state)
+}
+
+func errorPageGet(ctx context.Context, scope linkModels.ErrorPageScope, host string, owner primitive.ObjectID,
+	state linkModels.LinkState) (page *linkModels.ErrorPageInfo, err error) {
+	key := strconv.Itoa(int(scope)) + shSplit + host + shSplit + owner.Hex() + shSplit + strconv.Itoa(int(state))
+	if page, hit := errorPageCache.Get(key); hit {
+		return page, nil
+	}
+
+	page, _, err = linkModels.ErrorPageFind(ctx, scope, host, owner, state)
+	if err != nil {
+		return
+	}
+	errorPageCache.Set(key, page, errorPageCacheTTL)
+
+	return page, nil
+}
@@ -4,136 +4,260 @@ import (
 	"URLS/internal/common"
 	"URLS/internal/utils/bytestream"
 	linkModels "URLS/link/models"
+	"URLS/link/pkg/passthrough"
 	"context"
 	"errors"
 	"fmt"
 
 	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
+// linkLoadGroup 避免同一個短網址同時向 redis、mongo 發出多個查詢
+var linkLoadGroup singleflight.Group
+
 // shSplit short 和 host 之間的分隔符號
 const shSplit = "$"
 
 // CurDBDBSerializerMethod 當前的將資料寫入 DB 的方式
-const CurDBDBSerializerMethod = DataEncodeMethodV1
+const CurDBDBSerializerMethod = DataEncodeMethodV5
 
 const (
 	// 將資料寫入 DB 的方式
 	_ byte = iota
 	DataEncodeMethodV1
+	DataEncodeMethodV2 // 加入 query passthrough 設定
+	DataEncodeMethodV3 // 加入導向時的 HTTP 狀態碼
+	DataEncodeMethodV4 // 加入建立者，已刪除的短網址也會寫入
+	DataEncodeMethodV5 // 已刪除的短網址保留類型，用來判斷是否為已刪除的前綴短網址
 )
 
+// LinkRecord redirector 導向時需要的短網址資料
+type LinkRecord struct {
+	Type         linkModels.LinkType
+	FullDest     string
+	Deleted      bool
+	Passthrough  *passthrough.Policy // 為空時不傳遞造訪時的 query
+	RedirectCode int                 // 為 0 時使用預設值
+	Creator      primitive.ObjectID  // 用來查詢使用者的錯誤頁面，舊的資料為空
+}
+
+// linkInfoEncode 將 link 編碼為寫入 redis 的資料，已被刪除的 link 只保留建立者與類型
 func linkInfoEncode(info *linkModels.LinkInfo) []byte {
 	w := bytestream.NewWriter()
-	fullDest := info.FullDest()
 	w.Byte(CurDBDBSerializerMethod).
-		Bool(false). // 沒被刪除
-		Int32(int32(info.Type)).
-		String(fullDest)
+		Bool(info.Deleted).
+		String(info.Creator.Hex()).
+		Int32(int32(info.Type))
+	if info.Deleted {
+		return w.ToBytes()
+	}
 
-	return w.ToBytes()
-}
+	w.String(info.FullDest())
 
-func deleteLinkInfoEncode() []byte {
-	w := bytestream.NewWriter()
-	w.Byte(CurDBDBSerializerMethod).Bool(true)
+	policy := info.Passthrough.Policy()
+	w.Bool(policy != nil)
+	if policy != nil {
+		w.Int32(int32(policy.Conflict)).Int(len(policy.Allow))
+		for _, name := range policy.Allow {
+			w.String(name)
+		}
+	}
+	w.Int32(int32(info.RedirectCode))
 
 	return w.ToBytes()
 }
 
-func linkInfoDecode(bs []byte) (t linkModels.LinkType, fullDest string, deleted bool, err error) {
+func linkInfoDecode(bs []byte) (record *LinkRecord, err error) {
 	r := bytestream.NewReader(bs)
+	record = new(LinkRecord)
+
+	if len(bs) == 0 {
+		err = errors.New("bytes len is zero")
+		return
+	}
+
+	var encMethod byte
+	r.Byte(&encMethod)
+	switch encMethod {
+	case DataEncodeMethodV1, DataEncodeMethodV2, DataEncodeMethodV3, DataEncodeMethodV4, DataEncodeMethodV5:
+	default:
+		err = fmt.Errorf("unknow method(%d)", bs[0])
+		return
+	}
+
+	r.Bool(&record.Deleted)
+	if encMethod >= DataEncodeMethodV4 {
+		var creatorHex string
+		r.String(&creatorHex)
+		if r.HasErr() {
+			err = errors.New("deocde failed")
+			return
+		}
+		// 建立者無法解析時不影響導向
+		record.Creator, _ = primitive.ObjectIDFromHex(creatorHex)
+	}
+	// V5 之前已刪除的短網址沒有類型
+	if record.Deleted && encMethod < DataEncodeMethodV5 {
+		return
+	}
+
+	var linkTypeUint32 int32
+	r.Int32(&linkTypeUint32)
+	var convOK bool
+	record.Type, convOK = linkModels.LinkTypeFromInteger(linkTypeUint32)
+	if !convOK {
+		err = fmt.Errorf("unknow LinkType(%d)", linkTypeUint32)
+		return
+	}
+	if record.Deleted {
+		return
+	}
+	r.String(&record.FullDest)
 
-	if len(bs) > 0 {
-		var encMethod byte
-		r.Byte(&encMethod)
-		switch encMethod {
-		case DataEncodeMethodV1:
-			var linkTypeUint32 int32
-			r.Bool(&deleted)
-			if deleted {
+	if encMethod >= DataEncodeMethodV2 {
+		var hasPassthrough bool
+		r.Bool(&hasPassthrough)
+		if hasPassthrough {
+			var conflictInt32 int32
+			var allowNum int
+			r.Int32(&conflictInt32).Int(&allowNum)
+			if r.HasErr() || allowNum < 0 || allowNum > len(bs) {
+				err = errors.New("deocde failed")
 				return
 			}
 
-			r.Int32(&linkTypeUint32)
-			var convOK bool
-			t, convOK = linkModels.LinkTypeFromInteger(linkTypeUint32)
+			policy := &passthrough.Policy{Allow: make([]string, allowNum)}
+			policy.Conflict, convOK = passthrough.ConflictFromInteger(conflictInt32)
 			if !convOK {
-				err = fmt.Errorf("unknow LinkType(%d)", linkTypeUint32)
+				err = fmt.Errorf("unknow passthrough Conflict(%d)", conflictInt32)
 				return
 			}
-
-			r.String(&fullDest)
-			if r.HasErr() {
-				err = errors.New("deocde failed")
-				return
+			for i := range policy.Allow {
+				r.String(&policy.Allow[i])
 			}
-		default:
-			err = fmt.Errorf("unknow method(%d)", bs[0])
-			return
+			record.Passthrough = policy
 		}
-	} else {
-		err = errors.New("bytes len is zero")
+	}
+	if encMethod >= DataEncodeMethodV3 {
+		var redirectCode int32
+		r.Int32(&redirectCode)
+		record.RedirectCode = int(redirectCode)
+	}
+	if r.HasErr() {
+		err = errors.New("deocde failed")
 		return
 	}
 
-	return t, fullDest, deleted, nil
+	return record, nil
 }
 
 func linkKey(short, host string) string {
 	return short + shSplit + host
 }
 
-func LinkAdd(ctx context.Context, info *linkModels.LinkInfo) (err error) {
-	infoBs := linkInfoEncode(info)
-
-	var redisSetOk bool
-	redisSetOk, err = redisDB.SetNX(ctx, linkKey(info.Short, info.Host), infoBs, 0).Result()
+// LinkSync 將 link 目前的狀態寫入 redis，已被刪除的 link 會被寫入為已刪除
+//
+// 寫入的內容只與 link 的狀態有關，重複執行的結果相同
+func LinkSync(ctx context.Context, info *linkModels.LinkInfo) (err error) {
+	_, err = redisDB.Set(ctx, linkKey(info.Short, info.Host), linkInfoEncode(info), 0).Result()
 	if err != nil {
-		logger.Error("redisDB.SetNX failed", zap.Error(err))
+		logger.Error("redisDB.Set failed", zap.Error(err))
 		err = common.GRPCErrInternal
 		return
 	}
-	if !redisSetOk {
-		logger.Error("duplicate short+host want write to redisDB")
+	linkCacheInvalidate(ctx, linkKey(info.Short, info.Host))
+
+	return nil
+}
+
+// LinkGetInfo 取得短網址資料，啟用快取時優先使用快取
+//
+// fallback 為 true 時，redis 中沒有資料會改從 mongo 查詢並寫回 redis，同一個短網址同時只會有一個查詢。
+// 為 false 時只查詢 redis，且不存在的結果不會被快取，避免之後允許 fallback 的查詢讀到快取
+func LinkGetInfo(ctx context.Context, short, host string, fallback bool) (record *LinkRecord, exist bool, err error) {
+	key := linkKey(short, host)
+	if record, hit := linkCacheGet(key); hit {
+		return record, record != nil, nil
+	}
+	gen := linkCacheGen.Load()
+
+	if !fallback {
+		record, exist, err = LinkRecordInspect(ctx, short, host)
+		if err == nil && exist {
+			linkCacheSet(key, record, gen)
+		}
+		return record, exist, err
+	}
+
+	v, err, _ := linkLoadGroup.Do(key, func() (interface{}, error) {
+		return linkRecordLoad(ctx, short, host)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	record = v.(*LinkRecord)
+	linkCacheSet(key, record, gen)
+
+	return record, record != nil, nil
+}
+
+// linkRecordLoad 從 redis 讀取短網址資料，redis 中沒有資料時從 mongo 查詢，不存在時回傳 nil
+func linkRecordLoad(ctx context.Context, short, host string) (record *LinkRecord, err error) {
+	record, exist, err := LinkRecordInspect(ctx, short, host)
+	if err != nil || exist {
+		return record, err
+	}
+
+	return linkRecordRepopulate(ctx, short, host)
+}
+
+// linkRecordRepopulate 從 mongo 查詢 redis 中遺失的短網址資料並寫回 redis，不存在時回傳 nil
+func linkRecordRepopulate(ctx context.Context, short, host string) (record *LinkRecord, err error) {
+	link, exist, err := linkModels.LinkFindByShort(ctx, short, host)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	infoBs := linkInfoEncode(link)
+	record, err = linkInfoDecode(infoBs)
+	if err != nil {
+		logger.Error("linkInfoDecode failed", zap.Error(err))
 		err = common.GRPCErrInternal
 		return
 	}
 
-	return
+	// 寫回失敗時仍然可以使用 mongo 的資料導向，不覆蓋同時間由 link service 寫入的資料
+	_, err = redisDB.SetNX(ctx, linkKey(short, host), infoBs, 0).Result()
+	if err != nil {
+		logger.Error("redisDB.SetNX failed", zap.Error(err))
+		return record, nil
+	}
+	logger.Warn("link record was missing in redis, repopulated from mongo",
+		zap.String("short", short), zap.String("host", host))
+
+	return record, nil
 }
 
-func LinkGetInfo(ctx context.Context, short, host string) (fullDest string, deleted, exist bool, err error) {
+// LinkRecordInspect 回傳 redis 中 (short, host) 解析後的資料，不使用快取與 mongo
+func LinkRecordInspect(ctx context.Context, short, host string) (record *LinkRecord, exist bool, err error) {
 	linkBs, err := redisDB.Get(ctx, linkKey(short, host)).Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			exist = false
-			err = nil
-			return
+			return nil, false, nil
 		}
 		logger.Error("redisDB.Get failed", zap.Error(err))
-		return
-	}
-	_, fullDest, deleted, err = linkInfoDecode(linkBs)
-	if err != nil {
-		logger.Error("linkInfoDecode failed", zap.Error(err))
 		err = common.GRPCErrInternal
 		return
 	}
-
-	exist = true
-	return
-}
-
-// LinkDelete 將對應的 (short, host) 資料設為已被刪除
-func LinkDelete(ctx context.Context, info *linkModels.LinkInfo) (err error) {
-	_, err = redisDB.Set(ctx, linkKey(info.Short, info.Host), deleteLinkInfoEncode(), 0).Result()
+	record, err = linkInfoDecode(linkBs)
 	if err != nil {
-		logger.Error("redisDB.Set failed", zap.Error(err))
+		logger.Error("linkInfoDecode failed", zap.Error(err))
 		err = common.GRPCErrInternal
 		return
 	}
 
-	return nil
+	return record, true, nil
 }
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"URLS/internal/utils/bytestream"
+	linkModels "URLS/link/models"
+	"URLS/link/pkg/passthrough"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLinkInfoDecode(t *testing.T) {
+	creator := primitive.NewObjectID()
+	dest := "https://example.com/a?b=1"
+	policy := &passthrough.Policy{Conflict: passthrough.ConflictStored, Allow: []string{"utm_source", "ref"}}
+
+	tests := []struct {
+		name string
+		bs   []byte
+		want *LinkRecord
+	}{
+		{
+			"v1",
+			bytestream.NewWriter().Byte(DataEncodeMethodV1).Bool(false).
+				Int32(int32(linkModels.LTDirect)).String(dest).ToBytes(),
+			&LinkRecord{Type: linkModels.LTDirect, FullDest: dest},
+		},
+		{
+			"v1 deleted",
+			bytestream.NewWriter().Byte(DataEncodeMethodV1).Bool(true).ToBytes(),
+			&LinkRecord{Deleted: true},
+		},
+		{
+			"v2 without passthrough",
+			bytestream.NewWriter().Byte(DataEncodeMethodV2).Bool(false).
+				Int32(int32(linkModels.LTPrefix)).String(dest).Bool(false).ToBytes(),
+			&LinkRecord{Type: linkModels.LTPrefix, FullDest: dest},
+		},
+		{
+			"v2 passthrough",
+			bytestream.NewWriter().Byte(DataEncodeMethodV2).Bool(false).
+				Int32(int32(linkModels.LTDirect)).String(dest).
+				Bool(true).Int32(int32(policy.Conflict)).Int(2).String("utm_source").String("ref").ToBytes(),
+			&LinkRecord{Type: linkModels.LTDirect, FullDest: dest, Passthrough: policy},
+		},
+		{
+			"v2 deleted",
+			bytestream.NewWriter().Byte(DataEncodeMethodV2).Bool(true).ToBytes(),
+			&LinkRecord{Deleted: true},
+		},
+		{
+			"v3",
+			bytestream.NewWriter().Byte(DataEncodeMethodV3).Bool(false).
+				Int32(int32(linkModels.LTDirect)).String(dest).Bool(false).
+				Int32(http.StatusMovedPermanently).ToBytes(),
+			&LinkRecord{Type: linkModels.LTDirect, FullDest: dest, RedirectCode: http.StatusMovedPermanently},
+		},
+		{
+			"v3 deleted",
+			bytestream.NewWriter().Byte(DataEncodeMethodV3).Bool(true).ToBytes(),
+			&LinkRecord{Deleted: true},
+		},
+		{
+			"v4",
+			bytestream.NewWriter().Byte(DataEncodeMethodV4).Bool(false).String(creator.Hex()).
+				Int32(int32(linkModels.LTDirect)).String(dest).Bool(false).Int32(0).ToBytes(),
+			&LinkRecord{Type: linkModels.LTDirect, FullDest: dest, Creator: creator},
+		},
+		{
+			"v4 deleted",
+			bytestream.NewWriter().Byte(DataEncodeMethodV4).Bool(true).String(creator.Hex()).ToBytes(),
+			&LinkRecord{Deleted: true, Creator: creator},
+		},
+		{
+			"v5",
+			bytestream.NewWriter().Byte(DataEncodeMethodV5).Bool(false).String(creator.Hex()).
+				Int32(int32(linkModels.LTPrefix)).String(dest).Bool(false).Int32(0).ToBytes(),
+			&LinkRecord{Type: linkModels.LTPrefix, FullDest: dest, Creator: creator},
+		},
+		{
+			"v5 deleted",
+			bytestream.NewWriter().Byte(DataEncodeMethodV5).Bool(true).String(creator.Hex()).
+				Int32(int32(linkModels.LTPrefix)).ToBytes(),
+			&LinkRecord{Type: linkModels.LTPrefix, Deleted: true, Creator: creator},
+		},
+		{
+			// 建立者無法解析時不影響導向
+			"v4 invalid creator",
+			bytestream.NewWriter().Byte(DataEncodeMethodV4).Bool(false).String("").
+				Int32(int32(linkModels.LTDirect)).String(dest).Bool(false).Int32(0).ToBytes(),
+			&LinkRecord{Type: linkModels.LTDirect, FullDest: dest},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := linkInfoDecode(tt.bs)
+		if err != nil {
+			t.Errorf("%s: linkInfoDecode() err = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: linkInfoDecode() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLinkInfoDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		bs   []byte
+	}{
+		{"empty", nil},
+		{"unknown method", []byte{CurDBDBSerializerMethod + 1, 0}},
+		{"truncated", bytestream.NewWriter().Byte(DataEncodeMethodV3).Bool(false).
+			Int32(int32(linkModels.LTDirect)).ToBytes()},
+		{"unknown type", bytestream.NewWriter().Byte(DataEncodeMethodV1).Bool(false).
+			Int32(99).String("https://example.com").ToBytes()},
+		{"v4 without creator", bytestream.NewWriter().Byte(DataEncodeMethodV4).Bool(true).ToBytes()},
+		{"v5 deleted without type", bytestream.NewWriter().Byte(DataEncodeMethodV5).Bool(true).
+			String(primitive.NewObjectID().Hex()).ToBytes()},
+	}
+
+	for _, tt := range tests {
+		if _, err := linkInfoDecode(tt.bs); err == nil {
+			t.Errorf("%s: linkInfoDecode() err = nil, want error", tt.name)
+		}
+	}
+}
+
+func TestLinkInfoEncodeRoundTrip(t *testing.T) {
+	creator := primitive.NewObjectID()
+
+	tests := []struct {
+		name string
+		info *linkModels.LinkInfo
+		want *LinkRecord
+	}{
+		{
+			"direct",
+			&linkModels.LinkInfo{Type: linkModels.LTDirect, Dest: "https://example.com/a",
+				Querys: map[string]string{"utm_source": "x"}, Creator: creator},
+			&LinkRecord{Type: linkModels.LTDirect, FullDest: "https://example.com/a?utm_source=x", Creator: creator},
+		},
+		{
+			"prefix with passthrough and redirect code",
+			&linkModels.LinkInfo{Type: linkModels.LTPrefix, Dest: "https://example.com/docs", Creator: creator,
+				Passthrough: &linkModels.LinkPassthroughInfo{
+					Conflict: passthrough.ConflictDrop, Allow: []string{"ref"}},
+				RedirectCode: http.StatusPermanentRedirect},
+			&LinkRecord{Type: linkModels.LTPrefix, FullDest: "https://example.com/docs", Creator: creator,
+				Passthrough:  &passthrough.Policy{Conflict: passthrough.ConflictDrop, Allow: []string{"ref"}},
+				RedirectCode: http.StatusPermanentRedirect},
+		},
+		{
+			"passthrough allows all",
+			&linkModels.LinkInfo{Type: linkModels.LTDirect, Dest: "https://example.com", Creator: creator,
+				Passthrough: &linkModels.LinkPassthroughInfo{Conflict: passthrough.ConflictIncoming}},
+			&LinkRecord{Type: linkModels.LTDirect, FullDest: "https://example.com", Creator: creator,
+				Passthrough: &passthrough.Policy{Conflict: passthrough.ConflictIncoming, Allow: []string{}}},
+		},
+		{
+			"deleted",
+			&linkModels.LinkInfo{Type: linkModels.LTPrefix, Dest: "https://example.com", Deleted: true, Creator: creator},
+			&LinkRecord{Type: linkModels.LTPrefix, Deleted: true, Creator: creator},
+		},
+	}
+
+	for _, tt := range tests {
+		bs := linkInfoEncode(tt.info)
+		if bs[0] != CurDBDBSerializerMethod {
+			t.Errorf("%s: linkInfoEncode() method = %d, want %d", tt.name, bs[0], CurDBDBSerializerMethod)
+		}
+		got, err := linkInfoDecode(bs)
+		if err != nil {
+			t.Errorf("%s: linkInfoDecode() err = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: linkInfoDecode(linkInfoEncode()) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
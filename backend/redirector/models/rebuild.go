@@ -0,0 +1,270 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+
+	linkModels "URLS/link/models"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// RebuildPhase 重建進行到的階段
+type RebuildPhase string
+
+const (
+	RPLinks   RebuildPhase = "links"   // 依 mongo 的 link 寫入 redis
+	RPOrphans RebuildPhase = "orphans" // 掃描 redis 中沒有對應 link 的資料
+	RPDone    RebuildPhase = "done"
+)
+
+var (
+	// ErrRebuildDone 進度已經是完成的狀態
+	ErrRebuildDone = errors.New("rebuild is already done")
+	// ErrRebuildDryRunMismatch 進度與這次重建的 DryRun 選項不同
+	ErrRebuildDryRunMismatch = errors.New("checkpoint dry run does not match options")
+)
+
+// rebuildSetScript 只有在 key 仍然是讀取時的值才寫入，避免以舊的 mongo 資料覆蓋同步程序寫入的新資料
+//
+// ARGV[1] 為 1 時表示讀取時 key 不存在，ARGV[2] 為讀取時的值，ARGV[3] 為要寫入的值，ARGV[4] 為失效通知的 channel
+var rebuildSetScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if ARGV[1] == '1' then
+	if cur then return 0 end
+elseif cur ~= ARGV[2] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[3])
+redis.call('PUBLISH', ARGV[4], KEYS[1])
+return 1
+`)
+
+// RebuildReport 重建的統計結果
+type RebuildReport struct {
+	Scanned  int64 `json:"scanned"`  // 掃描的 link 數量
+	Missing  int64 `json:"missing"`  // redis 中沒有資料的 link 數量
+	Stale    int64 `json:"stale"`    // redis 中的資料與 mongo 不同的 link 數量
+	Written  int64 `json:"written"`  // 寫入 redis 的數量
+	Skipped  int64 `json:"skipped"`  // 寫入前 key 已被其他程序修改而略過的數量
+	Keys     int64 `json:"keys"`     // 掃描的 redis key 數量，SCAN 可能回傳重複的 key
+	Orphaned int64 `json:"orphaned"` // 沒有對應 link 的 redis key 數量
+	Removed  int64 `json:"removed"`  // 被移除的孤立 key 數量
+}
+
+// RebuildCheckpoint 重建的進度，中斷後可以從此進度繼續
+type RebuildCheckpoint struct {
+	Phase  RebuildPhase  `json:"phase"`
+	DryRun bool          `json:"dryRun,omitempty"` // 是否為 DryRun 的進度，只能以相同的選項繼續
+	LastID string        `json:"lastID,omitempty"` // 已處理的最後一個 link id
+	Cursor uint64        `json:"cursor,omitempty"` // 掃描 redis 的 cursor
+	Report RebuildReport `json:"report"`
+}
+
+// RebuildOptions 重建的選項
+type RebuildOptions struct {
+	DryRun        bool  // 只回報差異，不修改 redis
+	BatchSize     int64 // 每個批次處理的數量
+	RemoveOrphans bool  // 是否移除孤立的 key，DryRun 時不會移除
+
+	// OnBatch 每個批次完成後呼叫，用來儲存進度，DryRun 時也會呼叫，回傳錯誤時停止重建
+	OnBatch func(cp *RebuildCheckpoint) error
+	// OnDrift 發現差異時呼叫，kind 為 missing、stale、orphaned
+	OnDrift func(kind, key string)
+}
+
+func (opts *RebuildOptions) drift(kind, key string) {
+	if opts.OnDrift != nil {
+		opts.OnDrift(kind, key)
+	}
+}
+
+// Rebuild 依照 mongo 中的 link 重建 redis 的資料，並找出 redis 中沒有對應 link 的 key
+//
+// cp 為上次中斷時的進度，從頭開始時傳入空的 RebuildCheckpoint，DryRun 的進度只能以 DryRun 繼續
+func Rebuild(ctx context.Context, cp *RebuildCheckpoint, opts *RebuildOptions) (err error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	switch cp.Phase {
+	case "":
+		cp.Phase = RPLinks
+		cp.DryRun = opts.DryRun
+	case RPDone:
+		return ErrRebuildDone
+	}
+	if cp.DryRun != opts.DryRun {
+		return ErrRebuildDryRunMismatch
+	}
+
+	if cp.Phase == RPLinks {
+		if err = rebuildLinks(ctx, cp, opts); err != nil {
+			return
+		}
+		cp.Phase = RPOrphans
+		cp.Cursor = 0
+		if err = rebuildCheckpoint(cp, opts); err != nil {
+			return
+		}
+	}
+	if cp.Phase == RPOrphans {
+		if err = rebuildOrphans(ctx, cp, opts); err != nil {
+			return
+		}
+		cp.Phase = RPDone
+		if err = rebuildCheckpoint(cp, opts); err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+func rebuildCheckpoint(cp *RebuildCheckpoint, opts *RebuildOptions) error {
+	if opts.OnBatch == nil {
+		return nil
+	}
+	return opts.OnBatch(cp)
+}
+
+func rebuildLinks(ctx context.Context, cp *RebuildCheckpoint, opts *RebuildOptions) (err error) {
+	var lastID primitive.ObjectID
+	if cp.LastID != "" {
+		if lastID, err = primitive.ObjectIDFromHex(cp.LastID); err != nil {
+			return
+		}
+	}
+
+	for {
+		var list []*linkModels.LinkInfo
+		list, err = linkModels.LinkListAfterID(ctx, lastID, opts.BatchSize)
+		if err != nil || len(list) == 0 {
+			return
+		}
+
+		keys := make([]string, 0, len(list))
+		for _, link := range list {
+			keys = append(keys, linkKey(link.Short, link.Host))
+		}
+		var values []interface{}
+		values, err = redisDB.MGet(ctx, keys...).Result()
+		if err != nil {
+			logger.Error("redisDB.MGet failed", zap.Error(err))
+			return
+		}
+
+		pipe := redisDB.Pipeline()
+		var cmds []*redis.Cmd
+		for i, link := range list {
+			expected := linkInfoEncode(link)
+
+			missing := "0"
+			actual, exist := values[i].(string)
+			switch {
+			case !exist:
+				missing = "1"
+				cp.Report.Missing++
+				opts.drift("missing", keys[i])
+			case !bytes.Equal([]byte(actual), expected):
+				cp.Report.Stale++
+				opts.drift("stale", keys[i])
+			default:
+				continue
+			}
+			if !opts.DryRun {
+				// 讀取 mongo 之後 key 可能已經被同步程序更新，只在 key 沒有改變時寫入
+				cmds = append(cmds, rebuildSetScript.Eval(ctx, pipe, []string{keys[i]},
+					missing, actual, expected, linkInvalidateChannel))
+			}
+		}
+		if len(cmds) > 0 {
+			if _, err = pipe.Exec(ctx); err != nil {
+				logger.Error("write rebuilt link records failed", zap.Error(err))
+				return
+			}
+			for _, cmd := range cmds {
+				var written int64
+				if written, err = cmd.Int64(); err != nil {
+					logger.Error("rebuildSetScript failed", zap.Error(err))
+					return
+				}
+				// 回傳 0 表示 key 已被其他程序修改
+				if written == 1 {
+					cp.Report.Written++
+				} else {
+					cp.Report.Skipped++
+				}
+			}
+		}
+
+		lastID = list[len(list)-1].Id
+		cp.LastID = lastID.Hex()
+		cp.Report.Scanned += int64(len(list))
+		if err = rebuildCheckpoint(cp, opts); err != nil {
+			return
+		}
+	}
+}
+
+func rebuildOrphans(ctx context.Context, cp *RebuildCheckpoint, opts *RebuildOptions) (err error) {
+	for {
+		var keys []string
+		keys, cp.Cursor, err = redisDB.Scan(ctx, cp.Cursor, "*"+shSplit+"*", opts.BatchSize).Result()
+		if err != nil {
+			logger.Error("redisDB.Scan failed", zap.Error(err))
+			return
+		}
+
+		// 依 host 分組查詢 mongo 中是否有對應的 link
+		hostShorts := make(map[string][]string)
+		for _, key := range keys {
+			short, host, found := strings.Cut(key, shSplit)
+			if !found {
+				continue
+			}
+			hostShorts[host] = append(hostShorts[host], short)
+		}
+		var orphans []string
+		for host, shorts := range hostShorts {
+			var exist map[string]struct{}
+			exist, err = linkModels.LinkShortsExist(ctx, host, shorts)
+			if err != nil {
+				return
+			}
+			for _, short := range shorts {
+				if _, ok := exist[short]; !ok {
+					orphans = append(orphans, linkKey(short, host))
+				}
+			}
+		}
+
+		cp.Report.Keys += int64(len(keys))
+		cp.Report.Orphaned += int64(len(orphans))
+		for _, key := range orphans {
+			opts.drift("orphaned", key)
+		}
+		if !opts.DryRun && opts.RemoveOrphans && len(orphans) > 0 {
+			var removed int64
+			removed, err = redisDB.Del(ctx, orphans...).Result()
+			if err != nil {
+				logger.Error("redisDB.Del failed", zap.Error(err))
+				return
+			}
+			for _, key := range orphans {
+				linkCacheInvalidate(ctx, key)
+			}
+			cp.Report.Removed += removed
+		}
+
+		if err = rebuildCheckpoint(cp, opts); err != nil {
+			return
+		}
+		if cp.Cursor == 0 {
+			return nil
+		}
+	}
+}
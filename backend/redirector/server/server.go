@@ -1,22 +1,34 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"URLS/internal/common"
 	"URLS/internal/fh"
+	rdPB "URLS/proto/gen/go/redirector/v1"
 	"URLS/redirector/configs"
 	"URLS/redirector/controllers"
+	"URLS/redirector/models"
 
 	"github.com/gowo9/fhlogger/fhzap"
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 const serviceName string = "redirector"
 
+// shutdownTimeout 收到結束訊號後等待連線結束的時間
+const shutdownTimeout = 10 * time.Second
+
 func NewFHServer(logger *zap.Logger) *fasthttp.Server {
 	s := &fasthttp.Server{
 		Logger:                fh.NewInternalLogger(logger),
@@ -58,6 +70,10 @@ func Run() {
 		log.Fatal()
 	}
 
+	if cfgInfo.AdminListenAddr != "" {
+		go runAdminServer(logger, cfgInfo.AdminListenAddr, rdCtrl)
+	}
+
 	addr := cfgInfo.GetListenAddr()
 	var ln net.Listener
 	var tlsConfig *tls.Config
@@ -75,10 +91,60 @@ func Run() {
 	restServer.TLSConfig = tlsConfig
 	restServer.Handler = fhzap.New(logger).Combined(rdCtrl.GetRestHandler())
 
+	shutdownDone := make(chan struct{})
+	go shutdownOnSignal(logger, restServer, shutdownDone)
+
 	logger.Sugar().Infof("%s listen %s", serviceName, addr)
 	err = restServer.Serve(ln)
-	_ = logger.Sync()
 	if err != nil {
+		_ = logger.Sync()
 		sugar.Fatalw("fasthttp.Serve failed", "err", err)
 	}
+
+	// 等待處理中的請求結束，點擊統計保存在記憶體中，結束前寫入資料庫
+	<-shutdownDone
+	linkNum, clickNum, err := models.ClicksFlush(context.Background())
+	if err != nil {
+		logger.Error("flush clicks before exit failed", zap.Error(err))
+	} else {
+		logger.Info("clicks flushed before exit", zap.Int("links", linkNum), zap.Uint64("clicks", clickNum))
+	}
+	_ = logger.Sync()
+}
+
+// shutdownOnSignal 收到 SIGINT 或 SIGTERM 時停止接受新的連線，處理中的請求結束後關閉 done
+func shutdownOnSignal(logger *zap.Logger, restServer *fasthttp.Server, done chan<- struct{}) {
+	defer close(done)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigCh
+	logger.Info("shutting down", zap.String("signal", sig.String()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := restServer.ShutdownWithContext(ctx); err != nil {
+		logger.Warn("fasthttp.Shutdown failed", zap.Error(err))
+	}
+}
+
+// runAdminServer 啟動管理用的 gRPC server，只接受使用內部憑證的連線
+func runAdminServer(logger *zap.Logger, addr string, rdCtrl *controllers.RedirectorController) {
+	tlsConfig, err := common.GenInternalTLSConfig(serviceName)
+	if err != nil {
+		logger.Fatal("common.GenInternalTLSConfig failed", zap.Error(err))
+	}
+
+	gs := common.NewGRPCServer(logger, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	rdPB.RegisterRDServiceServer(gs, rdCtrl)
+
+	ln, err := net.Listen("tcp4", addr)
+	if err != nil {
+		logger.Fatal("admin net listener failed", zap.String("addr", addr), zap.Error(err))
+	}
+
+	logger.Sugar().Infof("%s admin listen %s", serviceName, addr)
+	if err = gs.Serve(ln); err != nil {
+		logger.Fatal("admin gRPC serve failed", zap.Error(err))
+	}
 }
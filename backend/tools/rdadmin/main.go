@@ -0,0 +1,87 @@
+// rdadmin 呼叫 redirector 的管理介面 (RDService)
+//
+// 連線位址為 common 設定的 srvcaddrmap.rd.grpc，需要指向 redirector 設定的 adminlistenaddr。
+// 可以使用的指令為 ping、stats、flush、inspect、invalidate。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"URLS/internal/common"
+	rdPB "URLS/proto/gen/go/redirector/v1"
+	"URLS/redirector/configs"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const serviceName string = "redirector"
+
+func main() {
+	configDir := flag.String("config", "", "設定檔的資料夾，預設為 server-data/configs")
+	short := flag.String("short", "", "inspect、invalidate 的短網址")
+	host := flag.String("host", "", "inspect、invalidate 的短網址網域，預設的網域為空")
+	all := flag.Bool("all", false, "invalidate 時移除所有短網址的快取")
+	timeout := flag.Duration("timeout", 10*time.Second, "呼叫的逾時時間")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] ping|stats|flush|inspect|invalidate\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfgInfo := new(configs.RDSCfgInfo)
+	if err := common.ParseConfigFile(serviceName, *configDir, cfgInfo); err != nil {
+		log.Fatalf("common.ParseConfigFile failed, err=%s", err)
+	}
+	addr := cfgInfo.SrvcAddrMap.RD.GRPC
+	if addr == "" {
+		log.Fatal("srvcaddrmap.rd.grpc is not set")
+	}
+	logger, err := cfgInfo.GenZapConfig()
+	if err != nil {
+		log.Fatalf("GenZapConfig failed, err=%s", err)
+	}
+	bc, err := common.NewBaseController(&cfgInfo.BaseCfgInfo, logger)
+	if err != nil {
+		log.Fatalf("common.NewBaseController failed, err=%s", err)
+	}
+	if err = bc.SrvcConn.GenRDConn(addr); err != nil {
+		log.Fatalf("GenRDConn failed, err=%s", err)
+	}
+	rd := bc.SrvcConn.RD
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var resp any
+	switch flag.Arg(0) {
+	case "ping":
+		resp, err = rd.Ping(ctx, &emptypb.Empty{})
+	case "stats":
+		resp, err = rd.Stats(ctx, &emptypb.Empty{})
+	case "flush":
+		resp, err = rd.ClicksFlush(ctx, &emptypb.Empty{})
+	case "inspect":
+		resp, err = rd.RecordInspect(ctx, &rdPB.RecordInspectRequest{Short: *short, Host: *host})
+	case "invalidate":
+		resp, err = rd.CacheInvalidate(ctx, &rdPB.CacheInvalidateRequest{Short: *short, Host: *host, All: *all})
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s failed, err=%s", flag.Arg(0), err)
+	}
+
+	out, _ := json.MarshalIndent(resp, "", "  ")
+	fmt.Println(string(out))
+}
@@ -0,0 +1,117 @@
+// rdrebuild 依照 mongo 中的 link 重建 redirector 的 redis 資料
+//
+// 預設會寫入缺少或與 mongo 不同的資料，-dry-run 時只回報差異。
+// 每個批次完成後會將進度寫入 -checkpoint，中斷後以 -resume 從上次的進度繼續，
+// -dry-run 的進度只能以 -dry-run 繼續。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"URLS/internal/common"
+	linkModels "URLS/link/models"
+	"URLS/redirector/configs"
+	"URLS/redirector/models"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const serviceName string = "redirector"
+
+func main() {
+	configDir := flag.String("config", "", "設定檔的資料夾，預設為 server-data/configs")
+	dryRun := flag.Bool("dry-run", false, "只回報差異，不修改 redis")
+	batchSize := flag.Int64("batch", 500, "每個批次處理的數量")
+	removeOrphans := flag.Bool("remove-orphans", false, "移除 redis 中沒有對應 link 的 key")
+	checkpointPath := flag.String("checkpoint", "rdrebuild-checkpoint.json", "進度檔的路徑")
+	resume := flag.Bool("resume", false, "從進度檔的進度繼續")
+	verbose := flag.Bool("v", false, "輸出每個有差異的 key")
+	flag.Parse()
+
+	cfgInfo := new(configs.RDSCfgInfo)
+	if err := common.ParseConfigFile(serviceName, *configDir, cfgInfo); err != nil {
+		log.Fatalf("common.ParseConfigFile failed, err=%s", err)
+	}
+	logger, err := cfgInfo.GenZapConfig()
+	if err != nil {
+		log.Fatalf("GenZapConfig failed, err=%s", err)
+	}
+
+	cp := new(models.RebuildCheckpoint)
+	if *resume {
+		if cp, err = checkpointLoad(*checkpointPath); err != nil {
+			logger.Fatal("load checkpoint failed", zap.Error(err))
+		}
+	}
+
+	// 初始化 mongo 與 redis
+
+	ctx := context.Background()
+	bc, err := common.NewBaseController(&cfgInfo.BaseCfgInfo, logger)
+	if err != nil {
+		logger.Fatal("common.NewBaseController failed", zap.Error(err))
+	}
+	if err = linkModels.InitModels(ctx, bc.MgoClient, bc.MgoDB, logger); err != nil {
+		logger.Fatal("linkModels.InitModels failed", zap.Error(err))
+	}
+	redisOpts, err := cfgInfo.GenRedisOptions()
+	if err != nil {
+		logger.Fatal("GenRedisOptions failed", zap.Error(err))
+	}
+	redisOpts.DB = models.RedisIndex
+	rClient := redis.NewClient(redisOpts)
+	if err = rClient.Ping(ctx).Err(); err != nil {
+		logger.Fatal("redis client ping failed", zap.Error(err))
+	}
+	models.InitModels(rClient, logger)
+
+	err = models.Rebuild(ctx, cp, &models.RebuildOptions{
+		DryRun:        *dryRun,
+		BatchSize:     *batchSize,
+		RemoveOrphans: *removeOrphans,
+		OnBatch: func(cp *models.RebuildCheckpoint) error {
+			return checkpointSave(*checkpointPath, cp)
+		},
+		OnDrift: func(kind, key string) {
+			if *verbose {
+				fmt.Printf("%s\t%s\n", kind, key)
+			}
+		},
+	})
+	if err != nil && !errors.Is(err, models.ErrRebuildDone) {
+		logger.Fatal("models.Rebuild failed", zap.Error(err), zap.Any("checkpoint", cp))
+	}
+
+	report, _ := json.MarshalIndent(cp.Report, "", "  ")
+	fmt.Println(string(report))
+}
+
+func checkpointLoad(path string) (cp *models.RebuildCheckpoint, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	cp = new(models.RebuildCheckpoint)
+	err = json.Unmarshal(data, cp)
+	return
+}
+
+// checkpointSave 先寫入暫存檔再取代，避免中斷時留下不完整的進度檔
+func checkpointSave(path string, cp *models.RebuildCheckpoint) (err error) {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return
+	}
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return
+	}
+	return os.Rename(tmpPath, path)
+}
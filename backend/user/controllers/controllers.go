@@ -96,6 +96,9 @@ func (uc *UserController) resetUserQuota() {
 	uc.Logger.Info("reset user quota")
 	bgCtx := context.Background()
 	err := models.UserQuotaReset(bgCtx)
+	if err == nil {
+		err = models.WorkspaceQuotaReset(bgCtx)
+	}
 	if err != nil {
 		// 失敗的話每10分鐘重試一次
 		const retryMin = 10
@@ -200,10 +200,47 @@ func (uc *UserController) LinkQuotaUpdate(ctx context.Context,
 		return
 	}
 
+	var toChangeWorkspaceID primitive.ObjectID
+	if req.GetWorkspaceIdHex() != "" {
+		toChangeWorkspaceID, err = primitive.ObjectIDFromHex(req.GetWorkspaceIdHex())
+		if err != nil {
+			err = status.Error(codes.InvalidArgument, "workspace id format is invalid")
+			return
+		}
+	}
+
 	if err = uc.IsInternalCall(ctx); err != nil {
 		return
 	}
 
+	patchInfo := &models.UserPatchInfo{
+		PNormalLinkQuota:    req.GetPatchNormalQuota(),
+		NormalLinkQuota:     req.GetNormalQuota(),
+		PCustomLinkQuota:    req.GetPatchCustomQuota(),
+		CustomLinkQuota:     req.GetCustomQuota(),
+		NormalLinkUsageDiff: req.GetNormalUsageDiff(),
+		CustomLinkUsageDiff: req.GetCustomUsageDiff(),
+		OpID:                req.GetOpId(),
+	}
+
+	// 工作區的短網址使用工作區的額度
+	if !toChangeWorkspaceID.IsZero() {
+		toChangeWorkspace, exist, err := models.WorkspaceFindByID(ctx, toChangeWorkspaceID)
+		if err != nil {
+			return nil, err
+		} else if !exist {
+			return nil, status.Error(codes.NotFound, "workspace was not found")
+		}
+		if err = toChangeWorkspace.Patch(ctx, patchInfo); err != nil {
+			return nil, err
+		}
+
+		resp = &userPB.LinkQuotaUpdateResponse{
+			Msg: "update success",
+		}
+		return resp, nil
+	}
+
 	toChangeUser, exist, err := models.UserFindByID(ctx, toChangeUserID)
 	if err != nil {
 		return
@@ -212,14 +249,7 @@ func (uc *UserController) LinkQuotaUpdate(ctx context.Context,
 		return
 	}
 
-	err = toChangeUser.Patch(ctx, &models.UserPatchInfo{
-		PNormalLinkQuota:    req.GetPatchNormalQuota(),
-		NormalLinkQuota:     req.GetNormalQuota(),
-		PCustomLinkQuota:    req.GetPatchCustomQuota(),
-		CustomLinkQuota:     req.GetCustomQuota(),
-		NormalLinkUsageDiff: req.GetNormalUsageDiff(),
-		CustomLinkUsageDiff: req.GetCustomUsageDiff(),
-	})
+	err = toChangeUser.Patch(ctx, patchInfo)
 	if err != nil {
 		return
 	}
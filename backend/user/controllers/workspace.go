@@ -0,0 +1,500 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"URLS/internal/common"
+	userPB "URLS/proto/gen/go/user/v1"
+	"URLS/user/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const workspaceNameMaxLen = 50
+
+// workspaceCreateMaxNum 一般使用者最多可以建立的工作區數量
+const workspaceCreateMaxNum = 2
+
+// workspaceNameArgumentCheck 檢查工作區名稱是否有效
+func workspaceNameArgumentCheck(name string) (err error) {
+	if strings.TrimSpace(name) == "" {
+		err = status.Error(codes.InvalidArgument, "workspace name can not be empty")
+		return
+	}
+	if len(name) > workspaceNameMaxLen {
+		err = status.Error(codes.InvalidArgument, "length of workspace name is greater than "+strconv.Itoa(workspaceNameMaxLen))
+		return
+	}
+
+	return nil
+}
+
+// workspaceRoleArgumentGet 轉換請求中的工作區權限
+func workspaceRoleArgumentGet(role uint32) (models.WorkspaceRole, error) {
+	wsRole, convOK := models.WorkspaceRoleFromInteger(role)
+	if !convOK {
+		return 0, status.Error(codes.InvalidArgument, "workspace role is not valid")
+	}
+	return wsRole, nil
+}
+
+// workspaceMemberGet 取得使用者在工作區中的成員資料，不是成員時回傳 NotFound
+func workspaceMemberGet(ctx context.Context, workspaceIDHex string, userID primitive.ObjectID) (
+	member *models.WorkspaceMemberInfo, err error) {
+	workspaceID, err := primitive.ObjectIDFromHex(workspaceIDHex)
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "workspace id format is invalid")
+		return
+	}
+
+	member, exist, err := models.WorkspaceMemberFind(ctx, workspaceID, userID)
+	if err != nil {
+		return
+	} else if !exist {
+		err = status.Error(codes.NotFound, "workspace was not found")
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// workspaceAdminGet 取得發送請求的使用者，並確認其為工作區管理員
+func (uc *UserController) workspaceAdminGet(ctx context.Context, workspaceIDHex string) (
+	reqUser *models.UserInfo, member *models.WorkspaceMemberInfo, err error) {
+	reqUser, err = uc.initRequestUser(ctx)
+	if err != nil {
+		return
+	}
+	member, err = workspaceMemberGet(ctx, workspaceIDHex, reqUser.Id)
+	if err != nil {
+		return
+	}
+	if member.Role != models.WRAdmin {
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+
+	return reqUser, member, nil
+}
+
+func mWorkspaceInfoToPBWorkspaceInfo(ws *models.WorkspaceInfo, role models.WorkspaceRole) *userPB.WorkspaceInfo {
+	return &userPB.WorkspaceInfo{
+		IdHex:        ws.Id.Hex(),
+		Name:         ws.Name,
+		CreatorIdHex: ws.Creator.Hex(),
+		Role:         uint32(role),
+		NormalQuota:  ws.NormalLinkQuota,
+		NormalUsage:  ws.NormalLinkUsage,
+		CustomQuota:  ws.CustomLinkQuota,
+		CustomUsage:  ws.CustomLinkUsage,
+	}
+}
+
+func (uc *UserController) WorkspaceCreate(ctx context.Context,
+	req *userPB.WorkspaceCreateRequest) (resp *userPB.WorkspaceCreateResponse, err error) {
+	if err = workspaceNameArgumentCheck(req.GetName()); err != nil {
+		return
+	}
+
+	reqUser, err := uc.initRequestUser(ctx)
+	if err != nil {
+		return
+	}
+
+	// 每個工作區都有自己的額度，限制數量避免以建立工作區取得額外的額度
+	if !reqUser.IsManager() {
+		var num int64
+		num, err = models.WorkspaceCountByCreator(ctx, reqUser.Id)
+		if err != nil {
+			return
+		}
+		if num >= workspaceCreateMaxNum {
+			err = status.Error(codes.ResourceExhausted, "the number of workspaces has reached the limit")
+			return
+		}
+	}
+
+	ws, err := models.WorkspaceCreate(ctx, req.GetName(), reqUser.Id)
+	if err != nil {
+		return
+	}
+
+	resp = &userPB.WorkspaceCreateResponse{
+		WorkspaceInfo: mWorkspaceInfoToPBWorkspaceInfo(ws, models.WRAdmin),
+	}
+	return resp, nil
+}
+
+func (uc *UserController) WorkspaceList(ctx context.Context,
+	req *userPB.WorkspaceListRequest) (resp *userPB.WorkspaceListResponse, err error) {
+	reqUser, err := uc.initRequestUser(ctx)
+	if err != nil {
+		return
+	}
+
+	members, err := models.WorkspaceMemberListByUser(ctx, reqUser.Id)
+	if err != nil {
+		return
+	}
+	roles := make(map[primitive.ObjectID]models.WorkspaceRole, len(members))
+	ids := make([]primitive.ObjectID, 0, len(members))
+	for _, m := range members {
+		roles[m.Workspace] = m.Role
+		ids = append(ids, m.Workspace)
+	}
+
+	list, err := models.WorkspaceListByIDs(ctx, ids)
+	if err != nil {
+		return
+	}
+	pbList := make([]*userPB.WorkspaceInfo, 0, len(list))
+	for _, ws := range list {
+		pbList = append(pbList, mWorkspaceInfoToPBWorkspaceInfo(ws, roles[ws.Id]))
+	}
+
+	resp = &userPB.WorkspaceListResponse{
+		WorkspaceInfoList: pbList,
+	}
+	return resp, nil
+}
+
+func (uc *UserController) WorkspacePatch(ctx context.Context,
+	req *userPB.WorkspacePatchRequest) (resp *userPB.WorkspacePatchResponse, err error) {
+	if err = workspaceNameArgumentCheck(req.GetName()); err != nil {
+		return
+	}
+
+	_, member, err := uc.workspaceAdminGet(ctx, req.GetWorkspaceIdHex())
+	if err != nil {
+		return
+	}
+
+	ws, exist, err := models.WorkspaceFindByID(ctx, member.Workspace)
+	if err != nil {
+		return
+	} else if !exist {
+		err = status.Error(codes.NotFound, "workspace was not found")
+		return
+	}
+	if err = ws.Rename(ctx, req.GetName()); err != nil {
+		return
+	}
+
+	resp = &userPB.WorkspacePatchResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (uc *UserController) WorkspaceMemberList(ctx context.Context,
+	req *userPB.WorkspaceMemberListRequest) (resp *userPB.WorkspaceMemberListResponse, err error) {
+	reqUser, err := uc.initRequestUser(ctx)
+	if err != nil {
+		return
+	}
+	reqMember, err := workspaceMemberGet(ctx, req.GetWorkspaceIdHex(), reqUser.Id)
+	if err != nil {
+		return
+	}
+
+	members, err := models.WorkspaceMemberList(ctx, reqMember.Workspace)
+	if err != nil {
+		return
+	}
+	userIDs := make([]primitive.ObjectID, 0, len(members))
+	for _, m := range members {
+		userIDs = append(userIDs, m.User)
+	}
+	users, err := models.UserListByIDs(ctx, userIDs)
+	if err != nil {
+		return
+	}
+	emails := make(map[primitive.ObjectID]string, len(users))
+	for _, u := range users {
+		emails[u.Id] = u.Email
+	}
+
+	pbList := make([]*userPB.WorkspaceMemberInfo, 0, len(members))
+	for _, m := range members {
+		pbList = append(pbList, &userPB.WorkspaceMemberInfo{
+			UserIdHex: m.User.Hex(),
+			Email:     emails[m.User],
+			Role:      uint32(m.Role),
+		})
+	}
+
+	resp = &userPB.WorkspaceMemberListResponse{
+		MemberList: pbList,
+	}
+	return resp, nil
+}
+
+// WorkspaceMemberGet 提供其他 service 確認使用者在工作區中的權限
+func (uc *UserController) WorkspaceMemberGet(ctx context.Context,
+	req *userPB.WorkspaceMemberGetRequest) (resp *userPB.WorkspaceMemberGetResponse, err error) {
+	userID, err := primitive.ObjectIDFromHex(req.GetUserIdHex())
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "user id format is invalid")
+		return
+	}
+
+	if err = uc.IsInternalCall(ctx); err != nil {
+		return
+	}
+
+	member, err := workspaceMemberGet(ctx, req.GetWorkspaceIdHex(), userID)
+	if err != nil {
+		return
+	}
+	ws, exist, err := models.WorkspaceFindByID(ctx, member.Workspace)
+	if err != nil {
+		return
+	} else if !exist {
+		err = status.Error(codes.NotFound, "workspace was not found")
+		return
+	}
+
+	resp = &userPB.WorkspaceMemberGetResponse{
+		WorkspaceInfo: mWorkspaceInfoToPBWorkspaceInfo(ws, member.Role),
+	}
+	return resp, nil
+}
+
+// workspaceLastAdminCheck 避免工作區中沒有任何管理員
+func workspaceLastAdminCheck(ctx context.Context, member *models.WorkspaceMemberInfo) (err error) {
+	if member.Role != models.WRAdmin {
+		return nil
+	}
+
+	adminNum, err := models.WorkspaceAdminCount(ctx, member.Workspace)
+	if err != nil {
+		return
+	}
+	if adminNum <= 1 {
+		err = status.Error(codes.FailedPrecondition, "workspace needs at least one admin")
+		return
+	}
+
+	return nil
+}
+
+func (uc *UserController) WorkspaceMemberRoleChange(ctx context.Context,
+	req *userPB.WorkspaceMemberRoleChangeRequest) (resp *userPB.WorkspaceMemberRoleChangeResponse, err error) {
+	newRole, err := workspaceRoleArgumentGet(req.GetRole())
+	if err != nil {
+		return
+	}
+	toChangeUserID, err := primitive.ObjectIDFromHex(req.GetUserIdHex())
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "user id format is invalid")
+		return
+	}
+
+	_, _, err = uc.workspaceAdminGet(ctx, req.GetWorkspaceIdHex())
+	if err != nil {
+		return
+	}
+
+	toChangeMember, err := workspaceMemberGet(ctx, req.GetWorkspaceIdHex(), toChangeUserID)
+	if err != nil {
+		return
+	}
+	if newRole != models.WRAdmin {
+		if err = workspaceLastAdminCheck(ctx, toChangeMember); err != nil {
+			return
+		}
+	}
+	if err = toChangeMember.UpdateRole(ctx, newRole); err != nil {
+		return
+	}
+
+	resp = &userPB.WorkspaceMemberRoleChangeResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (uc *UserController) WorkspaceMemberRemove(ctx context.Context,
+	req *userPB.WorkspaceMemberRemoveRequest) (resp *userPB.WorkspaceMemberRemoveResponse, err error) {
+	toRemoveUserID, err := primitive.ObjectIDFromHex(req.GetUserIdHex())
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "user id format is invalid")
+		return
+	}
+
+	reqUser, err := uc.initRequestUser(ctx)
+	if err != nil {
+		return
+	}
+	reqMember, err := workspaceMemberGet(ctx, req.GetWorkspaceIdHex(), reqUser.Id)
+	if err != nil {
+		return
+	}
+	// 成員可以自行離開，移除其他成員需要是管理員
+	if toRemoveUserID != reqUser.Id && reqMember.Role != models.WRAdmin {
+		err = common.GRPCERRPermissionDenied
+		return
+	}
+
+	toRemoveMember, err := workspaceMemberGet(ctx, req.GetWorkspaceIdHex(), toRemoveUserID)
+	if err != nil {
+		return
+	}
+	if err = workspaceLastAdminCheck(ctx, toRemoveMember); err != nil {
+		return
+	}
+	if err = toRemoveMember.Remove(ctx); err != nil {
+		return
+	}
+
+	resp = &userPB.WorkspaceMemberRemoveResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (uc *UserController) WorkspaceInvite(ctx context.Context,
+	req *userPB.WorkspaceInviteRequest) (resp *userPB.WorkspaceInviteResponse, err error) {
+	role, err := workspaceRoleArgumentGet(req.GetRole())
+	if err != nil {
+		return
+	}
+	if req.GetEmail() == "" {
+		err = status.Error(codes.InvalidArgument, "email can not be empty")
+		return
+	}
+
+	reqUser, member, err := uc.workspaceAdminGet(ctx, req.GetWorkspaceIdHex())
+	if err != nil {
+		return
+	}
+
+	// 已經是成員時不需要邀請
+	invitee, exist, err := models.UserFindByEmail(ctx, req.GetEmail())
+	if err != nil {
+		return
+	}
+	if exist {
+		_, exist, err = models.WorkspaceMemberFind(ctx, member.Workspace, invitee.Id)
+		if err != nil {
+			return
+		} else if exist {
+			err = status.Error(codes.AlreadyExists, "user is already a member of the workspace")
+			return
+		}
+	}
+
+	err = models.WorkspaceInviteCreate(ctx, member.Workspace, req.GetEmail(), role, reqUser.Id)
+	if err != nil {
+		return
+	}
+
+	resp = &userPB.WorkspaceInviteResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (uc *UserController) WorkspaceInviteList(ctx context.Context,
+	req *userPB.WorkspaceInviteListRequest) (resp *userPB.WorkspaceInviteListResponse, err error) {
+	reqUser, err := uc.initRequestUser(ctx)
+	if err != nil {
+		return
+	}
+
+	invites, err := models.WorkspaceInviteListByEmail(ctx, reqUser.Email)
+	if err != nil {
+		return
+	}
+	wsIDs := make([]primitive.ObjectID, 0, len(invites))
+	for _, invite := range invites {
+		wsIDs = append(wsIDs, invite.Workspace)
+	}
+	wsList, err := models.WorkspaceListByIDs(ctx, wsIDs)
+	if err != nil {
+		return
+	}
+	wsNames := make(map[primitive.ObjectID]string, len(wsList))
+	for _, ws := range wsList {
+		wsNames[ws.Id] = ws.Name
+	}
+
+	pbList := make([]*userPB.WorkspaceInviteInfo, 0, len(invites))
+	for _, invite := range invites {
+		pbList = append(pbList, &userPB.WorkspaceInviteInfo{
+			IdHex:          invite.Id.Hex(),
+			WorkspaceIdHex: invite.Workspace.Hex(),
+			WorkspaceName:  wsNames[invite.Workspace],
+			Role:           uint32(invite.Role),
+			InviterIdHex:   invite.Inviter.Hex(),
+		})
+	}
+
+	resp = &userPB.WorkspaceInviteListResponse{
+		InviteList: pbList,
+	}
+	return resp, nil
+}
+
+// workspaceInviteGet 取得發送給請求使用者的邀請
+func (uc *UserController) workspaceInviteGet(ctx context.Context, inviteIDHex string) (
+	reqUser *models.UserInfo, invite *models.WorkspaceInviteInfo, err error) {
+	inviteID, err := primitive.ObjectIDFromHex(inviteIDHex)
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, "invite id format is invalid")
+		return
+	}
+
+	reqUser, err = uc.initRequestUser(ctx)
+	if err != nil {
+		return
+	}
+
+	invite, exist, err := models.WorkspaceInviteFindByID(ctx, inviteID)
+	if err != nil {
+		return
+	} else if !exist || invite.Email != reqUser.Email {
+		err = status.Error(codes.NotFound, "invite was not found")
+		return
+	}
+
+	return reqUser, invite, nil
+}
+
+func (uc *UserController) WorkspaceInviteAccept(ctx context.Context,
+	req *userPB.WorkspaceInviteAcceptRequest) (resp *userPB.WorkspaceInviteAcceptResponse, err error) {
+	reqUser, invite, err := uc.workspaceInviteGet(ctx, req.GetInviteIdHex())
+	if err != nil {
+		return
+	}
+
+	if err = invite.Accept(ctx, reqUser.Id); err != nil {
+		return
+	}
+
+	resp = &userPB.WorkspaceInviteAcceptResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
+
+func (uc *UserController) WorkspaceInviteDecline(ctx context.Context,
+	req *userPB.WorkspaceInviteDeclineRequest) (resp *userPB.WorkspaceInviteDeclineResponse, err error) {
+	_, invite, err := uc.workspaceInviteGet(ctx, req.GetInviteIdHex())
+	if err != nil {
+		return
+	}
+
+	if err = invite.Delete(ctx); err != nil {
+		return
+	}
+
+	resp = &userPB.WorkspaceInviteDeclineResponse{
+		Msg: "success",
+	}
+	return resp, nil
+}
@@ -18,6 +18,9 @@ func InitModels(ctx context.Context, db *qmgo.Database, inLogger *zap.Logger) (e
 
 	userColl = mgoDB.Collection(UserCollName)
 	regInfoColl = mgoDB.Collection(RegInfoCollName)
+	workspaceColl = mgoDB.Collection(WorkspaceCollName)
+	workspaceMemberColl = mgoDB.Collection(WorkspaceMemberCollName)
+	workspaceInviteColl = mgoDB.Collection(WorkspaceInviteCollName)
 
 	err = initIndex(ctx)
 	return
@@ -27,6 +30,7 @@ func InitModels(ctx context.Context, db *qmgo.Database, inLogger *zap.Logger) (e
 func initIndex(ctx context.Context) (err error) {
 	var initFuncList = []func(context.Context) error{
 		initUserCollIndex,
+		initWorkspaceCollIndex,
 	}
 
 	for _, f := range initFuncList {
@@ -4,6 +4,7 @@ import (
 	"URLS/internal/common"
 	"URLS/internal/utils/bsonext"
 	"context"
+	"errors"
 
 	"github.com/qiniu/qmgo"
 	"github.com/qiniu/qmgo/field"
@@ -76,8 +77,6 @@ type UserInfo struct {
 	NormalLinkUsage uint64 `bson:"normallinkusage"` // 一般短網址使用量
 	CustomLinkQuota uint64 `bson:"customlinkquota"` // 自訂短網址額度
 	CustomLinkUsage uint64 `bson:"customlinkusage"` // 自訂短網址使用量
-
-	LinkTags []string `bson:"linktags,omitempty"` // 使用者定義的短網址 tags
 }
 
 // IsManager 使用者權限是否至少為管理員等級
@@ -185,6 +184,18 @@ func UserFindByID(ctx context.Context, id primitive.ObjectID) (u *UserInfo, exis
 	return
 }
 
+// UserListByIDs 根據 id 取得多個使用者
+func UserListByIDs(ctx context.Context, ids []primitive.ObjectID) (list []*UserInfo, err error) {
+	err = userColl.Find(ctx, bson.M{"_id": bsonext.In(ids)}).All(&list)
+	if err != nil {
+		logger.Error("list user by ids failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
 // UserQuotaReset 將所有使用者的使用額度清0
 func UserQuotaReset(ctx context.Context) (err error) {
 	_, err = userColl.UpdateAll(ctx, bson.M{}, bsonext.Set(bson.M{"normallinkusage": 0, "customlinkusage": 0}))
@@ -244,10 +255,34 @@ type UserPatchInfo struct {
 	CustomLinkQuota     uint64
 	NormalLinkUsageDiff int64
 	CustomLinkUsageDiff int64
+
+	// OpID 用來避免重複套用同一個更新，為空時不檢查
+	OpID string
 }
 
-// Patch 更新使用者資料
-func (u *UserInfo) Patch(ctx context.Context, pInfo *UserPatchInfo) (err error) {
+// quotaOpsMaxLen 每個使用者或工作區保留的已套用 OpID 數量
+const quotaOpsMaxLen = 200
+
+// toFilter 轉換為資料庫的查詢條件，已套用過 OpID 的資料不會被更新
+func (pInfo *UserPatchInfo) toFilter(id primitive.ObjectID) bson.M {
+	filter := bsonext.ID(id)
+	if pInfo.OpID != "" {
+		filter["quotaops"] = bson.M{"$ne": pInfo.OpID}
+	}
+	return filter
+}
+
+// patchErrConvert 轉換 Patch 的錯誤，OpID 已被套用過時回傳 nil
+func (pInfo *UserPatchInfo) patchErrConvert(err error, logMsg string) error {
+	if pInfo.OpID != "" && errors.Is(err, qmgo.ErrNoSuchDocuments) {
+		return nil
+	}
+	logger.Error(logMsg, zap.Error(err))
+	return common.GRPCErrInternal
+}
+
+// toUpdate 轉換為資料庫的更新內容
+func (pInfo *UserPatchInfo) toUpdate() bson.M {
 	setCol := bson.M{}
 	var incList bsonext.IncList
 	if pInfo.PNormalLinkQuota {
@@ -263,18 +298,23 @@ func (u *UserInfo) Patch(ctx context.Context, pInfo *UserPatchInfo) (err error)
 		incList.ADD("customlinkusage", pInfo.CustomLinkUsageDiff)
 	}
 	// TODO: 優化 bsonext 無法直接處理多項 $ 的問題
-	updateCol := bson.M{
+	update := bson.M{
 		"$set": bsonext.Set(setCol)["$set"],
 		"$inc": bsonext.Inc(incList)["$inc"],
 	}
+	if pInfo.OpID != "" {
+		update["$push"] = bson.M{"quotaops": bson.M{"$each": bson.A{pInfo.OpID}, "$slice": -quotaOpsMaxLen}}
+	}
+	return update
+}
 
+// Patch 更新使用者資料
+func (u *UserInfo) Patch(ctx context.Context, pInfo *UserPatchInfo) (err error) {
 	err = userColl.UpdateOne(ctx,
-		bsonext.ID(u.Id),
-		updateCol)
+		pInfo.toFilter(u.Id),
+		pInfo.toUpdate())
 	if err != nil {
-		logger.Error("user patch failed", zap.Error(err))
-		err = common.GRPCErrInternal
-		return
+		return pInfo.patchErrConvert(err, "user patch failed")
 	}
 
 	return nil
@@ -0,0 +1,399 @@
+package models
+
+import (
+	"URLS/internal/common"
+	"URLS/internal/utils/bsonext"
+	"context"
+	"time"
+
+	"github.com/qiniu/qmgo"
+	"github.com/qiniu/qmgo/field"
+	"github.com/qiniu/qmgo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	officialOpts "go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"golang.org/x/exp/constraints"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	WorkspaceCollName       string = "workspaces" + collSuffix
+	WorkspaceMemberCollName string = "workspacemembers" + collSuffix
+	WorkspaceInviteCollName string = "workspaceinvites" + collSuffix
+)
+
+var (
+	workspaceColl       *qmgo.Collection
+	workspaceMemberColl *qmgo.Collection
+	workspaceInviteColl *qmgo.Collection
+)
+
+const (
+	workspaceNormalLinkQuota = 200
+	workspaceCustomLinkQuota = 50
+
+	// workspaceInviteExpire 邀請的有效時間
+	workspaceInviteExpire = 7 * 24 * time.Hour
+)
+
+func initWorkspaceCollIndex(ctx context.Context) (err error) {
+	uniqueOpts := officialOpts.Index()
+	uniqueOpts.SetUnique(true)
+
+	err = workspaceColl.CreateOneIndex(ctx, options.IndexModel{Key: []string{"creator"}})
+	if err != nil {
+		return
+	}
+
+	err = workspaceMemberColl.CreateIndexes(ctx, []options.IndexModel{
+		{Key: []string{"workspace", "user"}, IndexOptions: uniqueOpts},
+		{Key: []string{"user"}},
+	})
+	if err != nil {
+		return
+	}
+
+	expireOpts := officialOpts.Index()
+	expireOpts.SetExpireAfterSeconds(0)
+
+	err = workspaceInviteColl.CreateIndexes(ctx, []options.IndexModel{
+		{Key: []string{"workspace", "email"}, IndexOptions: uniqueOpts},
+		{Key: []string{"email"}},
+		{Key: []string{"expireAt"}, IndexOptions: expireOpts},
+	})
+	return
+}
+
+// WorkspaceRole 工作區成員的權限
+type WorkspaceRole uint32
+
+const (
+	_        WorkspaceRole = iota
+	WRAdmin                // 管理成員與邀請，並可以編輯所有短網址
+	WREditor               // 可以建立、編輯、刪除工作區的短網址
+	WRViewer               // 只能查看工作區的短網址
+)
+
+func WorkspaceRoleFromInteger[T constraints.Integer](i T) (WorkspaceRole, bool) {
+	if int64(i) < int64(WRAdmin) || int64(i) > int64(WRViewer) {
+		return 0, false
+	}
+	return WorkspaceRole(i), true
+}
+
+// CanEdit 是否可以建立、編輯工作區的短網址
+func (r WorkspaceRole) CanEdit() bool {
+	return r == WRAdmin || r == WREditor
+}
+
+// WorkspaceInfo 多個使用者共同管理短網址的工作區
+//
+// 建立在工作區中的短網址使用工作區的額度
+type WorkspaceInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Name    string             `bson:"name"`
+	Creator primitive.ObjectID `bson:"creator"`
+
+	NormalLinkQuota uint64 `bson:"normallinkquota"` // 一般短網址額度
+	NormalLinkUsage uint64 `bson:"normallinkusage"` // 一般短網址使用量
+	CustomLinkQuota uint64 `bson:"customlinkquota"` // 自訂短網址額度
+	CustomLinkUsage uint64 `bson:"customlinkusage"` // 自訂短網址使用量
+}
+
+// WorkspaceMemberInfo 工作區成員
+type WorkspaceMemberInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Workspace primitive.ObjectID `bson:"workspace"`
+	User      primitive.ObjectID `bson:"user"`
+	Role      WorkspaceRole      `bson:"role"`
+}
+
+// WorkspaceInviteInfo 邀請使用者加入工作區，被邀請的使用者以 email 辨識
+type WorkspaceInviteInfo struct {
+	field.DefaultField `bson:",inline"`
+
+	Workspace primitive.ObjectID `bson:"workspace"`
+	Email     string             `bson:"email"`
+	Role      WorkspaceRole      `bson:"role"`
+	Inviter   primitive.ObjectID `bson:"inviter"`
+	ExpireAt  time.Time          `bson:"expireAt"`
+}
+
+// WorkspaceCreate 建立工作區，建立者成為管理員
+func WorkspaceCreate(ctx context.Context, name string, creator primitive.ObjectID) (ws *WorkspaceInfo, err error) {
+	ws = &WorkspaceInfo{
+		Name:            name,
+		Creator:         creator,
+		NormalLinkQuota: workspaceNormalLinkQuota,
+		CustomLinkQuota: workspaceCustomLinkQuota,
+	}
+	_, err = workspaceColl.InsertOne(ctx, ws)
+	if err != nil {
+		logger.Error("new workspace insert to db failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return nil, err
+	}
+
+	_, err = workspaceMemberColl.InsertOne(ctx, &WorkspaceMemberInfo{
+		Workspace: ws.Id,
+		User:      creator,
+		Role:      WRAdmin,
+	})
+	if err != nil {
+		_ = workspaceColl.Remove(ctx, bsonext.ID(ws.Id))
+		logger.Error("new workspace member insert to db failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return nil, err
+	}
+
+	return ws, nil
+}
+
+// WorkspaceCountByCreator 回傳使用者建立的工作區數量
+func WorkspaceCountByCreator(ctx context.Context, creator primitive.ObjectID) (num int64, err error) {
+	num, err = workspaceColl.Find(ctx, bson.M{"creator": creator}).Count()
+	if err != nil {
+		logger.Error("count workspace by creator failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// WorkspaceFindByID 根據 id 尋找工作區
+func WorkspaceFindByID(ctx context.Context, id primitive.ObjectID) (ws *WorkspaceInfo, exist bool, err error) {
+	ws = new(WorkspaceInfo)
+	err = workspaceColl.Find(ctx, bsonext.ID(id)).One(ws)
+	if err != nil {
+		ws = nil
+		if qmgo.IsErrNoDocuments(err) {
+			return nil, false, nil
+		}
+		logger.Error("find workspace by id failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return ws, true, nil
+}
+
+// WorkspaceListByIDs 根據 id 取得多個工作區
+func WorkspaceListByIDs(ctx context.Context, ids []primitive.ObjectID) (list []*WorkspaceInfo, err error) {
+	err = workspaceColl.Find(ctx, bson.M{"_id": bsonext.In(ids)}).Sort("name").All(&list)
+	if err != nil {
+		logger.Error("list workspace failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// WorkspaceQuotaReset 將所有工作區的使用額度清0
+func WorkspaceQuotaReset(ctx context.Context) (err error) {
+	_, err = workspaceColl.UpdateAll(ctx, bson.M{}, bsonext.Set(bson.M{"normallinkusage": 0, "customlinkusage": 0}))
+	if err != nil {
+		logger.Error("reset workspace quota failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// Rename 修改工作區名稱
+func (ws *WorkspaceInfo) Rename(ctx context.Context, name string) (err error) {
+	err = workspaceColl.UpdateOne(ctx, bsonext.ID(ws.Id), bsonext.Set(bson.M{"name": name}))
+	if err != nil {
+		logger.Error("rename workspace failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	ws.Name = name
+	return nil
+}
+
+// Patch 更新工作區的額度與使用量，欄位與 UserInfo.Patch 相同
+func (ws *WorkspaceInfo) Patch(ctx context.Context, pInfo *UserPatchInfo) (err error) {
+	err = workspaceColl.UpdateOne(ctx, pInfo.toFilter(ws.Id), pInfo.toUpdate())
+	if err != nil {
+		return pInfo.patchErrConvert(err, "workspace patch failed")
+	}
+
+	return nil
+}
+
+// WorkspaceMemberFind 尋找使用者在工作區中的成員資料
+func WorkspaceMemberFind(ctx context.Context, workspace, user primitive.ObjectID) (
+	member *WorkspaceMemberInfo, exist bool, err error) {
+	member = new(WorkspaceMemberInfo)
+	err = workspaceMemberColl.Find(ctx, bson.M{"workspace": workspace, "user": user}).One(member)
+	if err != nil {
+		member = nil
+		if qmgo.IsErrNoDocuments(err) {
+			return nil, false, nil
+		}
+		logger.Error("find workspace member failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return member, true, nil
+}
+
+// WorkspaceMemberListByUser 回傳使用者加入的所有工作區的成員資料
+func WorkspaceMemberListByUser(ctx context.Context, user primitive.ObjectID) (list []*WorkspaceMemberInfo, err error) {
+	err = workspaceMemberColl.Find(ctx, bson.M{"user": user}).All(&list)
+	if err != nil {
+		logger.Error("list workspace member by user failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// WorkspaceMemberList 回傳工作區的所有成員
+func WorkspaceMemberList(ctx context.Context, workspace primitive.ObjectID) (list []*WorkspaceMemberInfo, err error) {
+	err = workspaceMemberColl.Find(ctx, bson.M{"workspace": workspace}).Sort("createAt").All(&list)
+	if err != nil {
+		logger.Error("list workspace member failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// WorkspaceAdminCount 回傳工作區管理員的數量
+func WorkspaceAdminCount(ctx context.Context, workspace primitive.ObjectID) (num int64, err error) {
+	num, err = workspaceMemberColl.Find(ctx, bson.M{"workspace": workspace, "role": WRAdmin}).Count()
+	if err != nil {
+		logger.Error("count workspace admin failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// UpdateRole 更新成員權限
+func (m *WorkspaceMemberInfo) UpdateRole(ctx context.Context, role WorkspaceRole) (err error) {
+	err = workspaceMemberColl.UpdateOne(ctx, bsonext.ID(m.Id), bsonext.Set(bson.M{"role": role}))
+	if err != nil {
+		logger.Error("update workspace member role failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	m.Role = role
+	return nil
+}
+
+// Remove 將成員移出工作區
+func (m *WorkspaceMemberInfo) Remove(ctx context.Context) (err error) {
+	err = workspaceMemberColl.Remove(ctx, bsonext.ID(m.Id))
+	if err != nil {
+		logger.Error("remove workspace member failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
+
+// WorkspaceInviteCreate 邀請 email 加入工作區，重複邀請時會更新權限與有效時間
+func WorkspaceInviteCreate(ctx context.Context, workspace primitive.ObjectID, email string,
+	role WorkspaceRole, inviter primitive.ObjectID) (err error) {
+	upsertOpts := options.UpdateOptions{UpdateOptions: officialOpts.Update().SetUpsert(true)}
+	nowTime := time.Now()
+	err = workspaceInviteColl.UpdateOne(ctx,
+		bson.M{"workspace": workspace, "email": email},
+		bson.M{
+			"$set": bson.M{
+				"role":     role,
+				"inviter":  inviter,
+				"updateAt": nowTime,
+				"expireAt": nowTime.Add(workspaceInviteExpire),
+			},
+			"$setOnInsert": bson.M{"createAt": nowTime},
+		}, upsertOpts)
+	if err != nil {
+		logger.Error("upsert workspace invite failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}
+
+// WorkspaceInviteFindByID 根據 id 尋找未過期的邀請
+func WorkspaceInviteFindByID(ctx context.Context, id primitive.ObjectID) (
+	invite *WorkspaceInviteInfo, exist bool, err error) {
+	invite = new(WorkspaceInviteInfo)
+	err = workspaceInviteColl.Find(ctx, bson.M{"_id": id, "expireAt": bson.M{"$gt": time.Now()}}).One(invite)
+	if err != nil {
+		invite = nil
+		if qmgo.IsErrNoDocuments(err) {
+			return nil, false, nil
+		}
+		logger.Error("find workspace invite failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return invite, true, nil
+}
+
+// WorkspaceInviteListByEmail 回傳 email 收到的未過期邀請
+func WorkspaceInviteListByEmail(ctx context.Context, email string) (list []*WorkspaceInviteInfo, err error) {
+	err = workspaceInviteColl.Find(ctx, bson.M{"email": email, "expireAt": bson.M{"$gt": time.Now()}}).
+		Sort("-createAt").All(&list)
+	if err != nil {
+		logger.Error("list workspace invite failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return
+}
+
+// Accept 接受邀請，將 user 加入工作區並刪除邀請
+func (i *WorkspaceInviteInfo) Accept(ctx context.Context, user primitive.ObjectID) (err error) {
+	_, err = workspaceMemberColl.InsertOne(ctx, &WorkspaceMemberInfo{
+		Workspace: i.Workspace,
+		User:      user,
+		Role:      i.Role,
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			err = status.Error(codes.AlreadyExists, "already a member of the workspace")
+			_ = i.Delete(ctx)
+			return
+		}
+		logger.Error("new workspace member insert to db failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return i.Delete(ctx)
+}
+
+// Delete 刪除邀請
+func (i *WorkspaceInviteInfo) Delete(ctx context.Context) (err error) {
+	err = workspaceInviteColl.Remove(ctx, bsonext.ID(i.Id))
+	if err != nil && !qmgo.IsErrNoDocuments(err) {
+		logger.Error("delete workspace invite failed", zap.Error(err))
+		err = common.GRPCErrInternal
+		return
+	}
+
+	return nil
+}